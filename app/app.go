@@ -85,6 +85,22 @@ func InitModule(configPath string, modules []string) error {
 		log.Printf("[INFO] %s\n", " Postgres Config Done.")
 	}
 
+	// 加载分片配置，需在连接池之后加载
+	if util.InSliceString("sharding", modules) {
+		if err := InitShardingConfig(util.GetConfigPath("sharding")); err != nil {
+			fmt.Printf("[ERROR] %s InitShardingConfig: %s\n", time.Now().Format(util.DateTimeFormat), err.Error())
+		}
+		log.Printf("[INFO] %s\n", " Sharding Config Done.")
+	}
+
+	// 加载多租户配置，需在连接池之后加载
+	if util.InSliceString("tenancy", modules) {
+		if err := InitTenancyConfig(util.GetConfigPath("tenancy")); err != nil {
+			fmt.Printf("[ERROR] %s InitTenancyConfig: %s\n", time.Now().Format(util.DateTimeFormat), err.Error())
+		}
+		log.Printf("[INFO] %s\n", " Tenancy Config Done.")
+	}
+
 	// 设置时区
 	location, err := time.LoadLocation(BaseConf.Base.TimeLocation)
 	if err != nil {
@@ -99,6 +115,7 @@ func InitModule(configPath string, modules []string) error {
 func Destroy() {
 	log.Println("------------------------------------------------------------------------")
 	log.Printf("[INFO] %s\n", "Start Destroy Resources.") // 开始销毁加载的资源
+	CloseTenancy()                                        // 关闭租户连接池
 	err := CloseMySQLDB()                                 // 关闭数据库连接
 	if err != nil {
 		log.Printf("[INFO] %s\n", "Close MySQL Connect Failed.") // 关闭数据库连接失败
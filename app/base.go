@@ -1,13 +1,19 @@
 package app
 
-import "github.com/MetaverseTopDJ/Scaffold/util"
+import (
+	"fmt"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/util"
+)
 
 // BaseConf 全局变量
 var BaseConf *BaseConfig
 
 type BaseConfig struct {
-	Base Base       `mapstructure:"base"`
-	Http HttpConfig `mapstructure:"http"`
+	Base      Base            `mapstructure:"base"`
+	Http      HttpConfig      `mapstructure:"http"`
+	Snowflake SnowflakeConfig `mapstructure:"snowflake"`
 }
 
 // BaseConfig 基础配置结构体
@@ -26,6 +32,12 @@ type HttpConfig struct {
 	MaxHeaderBytes string `mapstructure:"max_header_bytes"`
 }
 
+// SnowflakeConfig 雪花 ID 配置
+type SnowflakeConfig struct {
+	WorkerID uint32 `mapstructure:"worker_id"` // 机器 ID 1 ~ 1023，为 0 时取本机 IP 低 10 位
+	Epoch    string `mapstructure:"epoch"`     // 起始时间 如 2018-05-14 08:04:44 (UTC)，为空时使用默认值
+}
+
 func InitBaseConfig(path string) error {
 	BaseConf = &BaseConfig{}
 	if err := util.ParseConfig(path, BaseConf); err != nil {
@@ -43,7 +55,29 @@ func InitBaseConfig(path string) error {
 	if BaseConf.Base.TimeLocation == "" {
 		BaseConf.Base.TimeLocation = "Asia/Shanghai"
 	}
-	return nil
+	// 设置 雪花 ID 生成器
+	return initSnowFlake(BaseConf.Snowflake)
+}
+
+// initSnowFlake 初始化进程级雪花 ID 生成器
+func initSnowFlake(config SnowflakeConfig) error {
+	workerID := config.WorkerID
+	if workerID == 0 {
+		if ip := util.LocalIP.To4(); ip != nil {
+			workerID = (uint32(ip[2])<<8 | uint32(ip[3])) & util.MaxWorkID
+		}
+		if workerID == 0 {
+			workerID = 1
+		}
+	}
+	var epoch time.Time
+	if config.Epoch != "" {
+		var err error
+		if epoch, err = time.Parse(util.DateTimeFormat, config.Epoch); err != nil {
+			return fmt.Errorf("invalid snowflake epoch %q: %v", config.Epoch, err)
+		}
+	}
+	return util.InitSnowFlake(workerID, epoch)
 }
 
 // GetEnv 获取环境名称
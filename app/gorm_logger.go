@@ -0,0 +1,144 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+const defaultSlowThreshold = time.Second * 2 // 默认慢 SQL 阀值
+
+// GormLoggerConfig gorm 日志配置
+type GormLoggerConfig struct {
+	Pool                      string              // 连接池名称
+	SuccessTag                string              // 成功记录标签
+	FailedTag                 string              // 失败记录标签
+	SlowThreshold             time.Duration       // 慢 SQL 阀值
+	Colorful                  bool                // 彩色打印 SQL
+	IgnoreRecordNotFoundError bool                // 忽略记录不存在错误
+	LogLevel                  gormLogger.LogLevel // 日志等级
+}
+
+// GormLogger 将 gorm 日志输出到 Scaffold logger，并携带上下文中的追踪信息
+type GormLogger struct {
+	GormLoggerConfig
+	log *logger.Logger
+}
+
+// NewGormLogger 创建 gorm 日志适配器
+func NewGormLogger(l *logger.Logger, config GormLoggerConfig) *GormLogger {
+	if config.SuccessTag == "" {
+		config.SuccessTag = logger.DLTagUndefined
+	}
+	if config.FailedTag == "" {
+		config.FailedTag = logger.DLTagUndefined
+	}
+	return &GormLogger{GormLoggerConfig: config, log: l}
+}
+
+// LogMode 设置日志等级
+func (l *GormLogger) LogMode(level gormLogger.LogLevel) gormLogger.Interface {
+	newLogger := *l
+	newLogger.LogLevel = level
+	return &newLogger
+}
+
+// Info 输出信息
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.LogLevel >= gormLogger.Info {
+		l.log.TagInfo(logger.TraceFromContext(ctx), l.SuccessTag, l.fields(msg, args...))
+	}
+}
+
+// Warn 警告日志
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.LogLevel >= gormLogger.Warn {
+		l.log.TagWarn(logger.TraceFromContext(ctx), l.SuccessTag, l.fields(msg, args...))
+	}
+}
+
+// Error 错误日志
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.LogLevel >= gormLogger.Error {
+		l.log.TagError(logger.TraceFromContext(ctx), l.FailedTag, l.fields(msg, args...))
+	}
+}
+
+// Trace 记录 SQL 执行情况
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.LogLevel <= gormLogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.LogLevel >= gormLogger.Error && (!errors.Is(err, gorm.ErrRecordNotFound) || !l.IgnoreRecordNotFoundError):
+		m := l.sqlFields(fc, elapsed)
+		m["error"] = err.Error()
+		l.log.TagError(logger.TraceFromContext(ctx), l.FailedTag, m)
+	case l.SlowThreshold != 0 && elapsed > l.SlowThreshold && l.LogLevel >= gormLogger.Warn:
+		m := l.sqlFields(fc, elapsed)
+		m["slow"] = fmt.Sprintf("SLOW SQL >= %v", l.SlowThreshold)
+		l.log.TagWarn(logger.TraceFromContext(ctx), l.SuccessTag, m)
+	case l.LogLevel == gormLogger.Info:
+		l.log.TagInfo(logger.TraceFromContext(ctx), l.SuccessTag, l.sqlFields(fc, elapsed))
+	}
+}
+
+// fields 普通消息字段
+func (l *GormLogger) fields(msg string, args ...interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"pool": l.Pool,
+		"msg":  strings.TrimSpace(fmt.Sprintf(msg, args...)),
+	}
+}
+
+// sqlFields SQL 记录字段
+func (l *GormLogger) sqlFields(fc func() (string, int64), elapsed time.Duration) map[string]interface{} {
+	sql, rows := fc()
+	if l.Colorful {
+		sql = gormLogger.Magenta + sql + gormLogger.Reset
+	}
+	m := map[string]interface{}{
+		"pool":      l.Pool,
+		"sql":       sql,
+		"rows":      rows,
+		"proc_time": fmt.Sprintf("%.3fms", float64(elapsed.Nanoseconds())/1e6),
+		"source":    utils.FileWithLineNum(),
+	}
+	if rows == -1 {
+		m["rows"] = "-"
+	}
+	return m
+}
+
+// ParseGormLogLevel 解析 gorm 日志等级，空值返回默认等级
+func ParseGormLogLevel(level string, def gormLogger.LogLevel) gormLogger.LogLevel {
+	switch strings.ToUpper(level) {
+	case "SILENT": // 静默
+		return gormLogger.Silent
+	case "ERROR":
+		return gormLogger.Error
+	case "WARNING", "WARN":
+		return gormLogger.Warn
+	case "INFO":
+		return gormLogger.Info
+	default:
+		return def
+	}
+}
+
+// ParseSlowThreshold 解析慢 SQL 阀值，支持 "500ms" "2s" 等格式
+func ParseSlowThreshold(threshold string) (time.Duration, error) {
+	if threshold == "" {
+		return defaultSlowThreshold, nil
+	}
+	return time.ParseDuration(threshold)
+}
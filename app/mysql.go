@@ -1,26 +1,20 @@
 package app
 
 import (
-	"errors"
+	"database/sql"
 	"fmt"
-	"log"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/MetaverseTopDJ/Scaffold/util"
 
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
 type MySQLConfig struct {
-	DriverName      string `mapstructure:"driver_name"`        // 数据库驱动
-	DataSourceName  string `mapstructure:"data_source_name"`   // 数据源
-	MaxOpenConn     int    `mapstructure:"max_open_conn"`      // 最大连接数
-	MaxIdleConn     int    `mapstructure:"max_idle_conn"`      // 空闲连接池的最大连接数
-	MaxConnLifeTime int    `mapstructure:"max_conn_life_time"` // 可以重用的最长连接时间
+	SQLConfig `mapstructure:",squash"`
 }
 
 type MySQLMapConfig struct {
@@ -28,72 +22,57 @@ type MySQLMapConfig struct {
 }
 
 var MySQLPool map[string]*gorm.DB
+var mysqlResolvers map[string]*dbresolver.DBResolver // 只读副本
 
 // InitMySQLPool 初始化 MySQL 数据库连接池
 func InitMySQLPool(path string, level string) error {
 	SetMySQLLogLevel(level) // 设置日志等级
-	MySQLConfigMap := &MySQLMapConfig{}
-	err := util.ParseConfig(path, MySQLConfigMap)
+	list, err := parseMySQLConfig(path)
 	if err != nil {
 		return err
 	}
-	if len(MySQLConfigMap.List) == 0 {
+	if len(list) == 0 {
 		fmt.Printf("[INFO] %s%s\n", time.Now().Format(util.DateTimeFormat), " empty mysql config.")
 	}
+	return initSQLPools(mysqlDriver, path, list)
+}
 
-	MySQLPool = map[string]*gorm.DB{}
+// parseMySQLConfig 解析配置文件中的命名连接池
+func parseMySQLConfig(path string) (map[string]*SQLConfig, error) {
+	MySQLConfigMap := &MySQLMapConfig{}
+	if err := util.ParseConfig(path, MySQLConfigMap); err != nil {
+		return nil, err
+	}
+	list := make(map[string]*SQLConfig, len(MySQLConfigMap.List))
 	for configName, config := range MySQLConfigMap.List {
-		dialector := mysql.New(mysql.Config{
-			DSN: config.DataSourceName,
-		})
-		DBGorm, err := gorm.Open(dialector, &gorm.Config{
-			QueryFields: true,
-			Logger: logger.New(
-				log.New(os.Stdout, "\r\n", log.LstdFlags),
-				logger.Config{
-					SlowThreshold: time.Second * 2, // 满 SQL 阀值
-					LogLevel:      logLevel,        // Log Level
-					Colorful:      true,            // 禁用彩色打印
-				},
-			),
-		})
-		if err != nil {
-			return err
-		}
-		PQ, err := DBGorm.DB()
-		if err == nil {
-			PQ.SetMaxOpenConns(config.MaxOpenConn)
-			PQ.SetMaxIdleConns(config.MaxIdleConn)
-			PQ.SetConnMaxLifetime(time.Duration(config.MaxConnLifeTime) * time.Second)
-			MySQLPool[configName] = DBGorm
-		} else {
-			return err
-		}
+		list[configName] = &config.SQLConfig
 	}
-	return nil
+	return list, nil
 }
 
 // GetMySQLPool GetGormPool 获取数据库连接
 func GetMySQLPool(name string) (*gorm.DB, error) {
-	if db, ok := MySQLPool[name]; ok {
-		return db, nil
-	}
-	return nil, errors.New("get pool error")
+	return getSQLPool(mysqlDriver, name)
+}
+
+// MySQLPools 获取所有已连接的 MySQL 连接池
+func MySQLPools() map[string]*gorm.DB {
+	return sqlPools(mysqlDriver)
+}
+
+// MySQLReplicas MySQL 命名连接池的只读副本快照
+func MySQLReplicas() map[string][]*sql.DB {
+	return sqlReplicas(mysqlDriver)
+}
+
+// SetMySQLPool 登记外部创建的 MySQL 连接池，db 为 nil 时移除，用于测试替身
+func SetMySQLPool(name string, db *gorm.DB) error {
+	return setSQLPool(mysqlDriver, name, db)
 }
 
 // CloseMySQLDB 关闭数据库
 func CloseMySQLDB() error {
-	for _, pool := range MySQLPool {
-		db, err := pool.DB()
-		if err != nil {
-			return err
-		}
-		err = db.Close()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return closeSQLPools(mysqlDriver)
 }
 
 // SetMySQLLogLevel 设置日志级别
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 连接池状态
+const (
+	PoolConnecting = "connecting" // 连接中
+	PoolReady      = "ready"      // 可用
+	PoolFailed     = "failed"     // 连接失败
+	PoolClosed     = "closed"     // 已关闭
+)
+
+// PoolStatus 连接池状态
+type PoolStatus struct {
+	Driver    string    // 驱动 mysql / postgres / redis
+	Name      string    // 连接池名称
+	State     string    // 状态
+	Attempts  int       // 连接尝试次数
+	LastError string    // 最近一次错误
+	Since     time.Time // 进入当前状态的时间
+}
+
+var (
+	poolStatusLock sync.RWMutex
+	poolStatusMap  = map[string]*PoolStatus{}
+)
+
+// setPoolStatus 更新连接池状态
+func setPoolStatus(driver, name, state string, attempts int, err error) {
+	poolStatusLock.Lock()
+	defer poolStatusLock.Unlock()
+	key := driver + "." + name
+	status, ok := poolStatusMap[key]
+	if !ok {
+		status = &PoolStatus{Driver: driver, Name: name}
+		poolStatusMap[key] = status
+	}
+	if status.State != state {
+		status.State = state
+		status.Since = time.Now()
+	}
+	if attempts > 0 {
+		status.Attempts = attempts
+	}
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+}
+
+// GetPoolStatus 获取连接池状态
+func GetPoolStatus(driver, name string) (PoolStatus, bool) {
+	poolStatusLock.RLock()
+	defer poolStatusLock.RUnlock()
+	if status, ok := poolStatusMap[driver+"."+name]; ok {
+		return *status, true
+	}
+	return PoolStatus{}, false
+}
+
+// IsPoolReady 判断连接池是否可用
+func IsPoolReady(driver, name string) bool {
+	status, ok := GetPoolStatus(driver, name)
+	return ok && status.State == PoolReady
+}
+
+// PoolStatuses 获取所有连接池状态
+func PoolStatuses() []PoolStatus {
+	poolStatusLock.RLock()
+	list := make([]PoolStatus, 0, len(poolStatusMap))
+	for _, status := range poolStatusMap {
+		list = append(list, *status)
+	}
+	poolStatusLock.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Driver != list[j].Driver {
+			return list[i].Driver < list[j].Driver
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
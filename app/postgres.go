@@ -1,26 +1,20 @@
 package app
 
 import (
-	"errors"
+	"database/sql"
 	"fmt"
-	"log"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/MetaverseTopDJ/Scaffold/util"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
 type PostgresConfig struct {
-	DriverName      string `mapstructure:"driver_name"`        // 数据库驱动
-	DataSourceName  string `mapstructure:"data_source_name"`   // 数据源
-	MaxOpenConn     int    `mapstructure:"max_open_conn"`      // 最大连接数
-	MaxIdleConn     int    `mapstructure:"max_idle_conn"`      // 空闲连接池的最大连接数
-	MaxConnLifeTime int    `mapstructure:"max_conn_life_time"` // 可以重用的最长连接时间
+	SQLConfig `mapstructure:",squash"`
 }
 
 type PostgresMapConfig struct {
@@ -29,71 +23,57 @@ type PostgresMapConfig struct {
 
 var logLevel = logger.Info
 var PostgresPool map[string]*gorm.DB
+var postgresResolvers map[string]*dbresolver.DBResolver // 只读副本
 
 // InitPostgresPool 初始化数据库连接 gorm 方式
 func InitPostgresPool(path string, level string) error {
 	SetPgSQLLogLevel(level) // 设置日志等级
-	DBConfigMap := &PostgresMapConfig{}
-	err := util.ParseConfig(path, DBConfigMap)
+	list, err := parsePostgresConfig(path)
 	if err != nil {
 		return err
 	}
-	if len(DBConfigMap.List) == 0 {
+	if len(list) == 0 {
 		fmt.Printf("[INFO] %s%s\n", time.Now().Format(util.DateTimeFormat), " empty postgres config.")
 	}
-	PostgresPool = map[string]*gorm.DB{}
+	return initSQLPools(postgresDriver, path, list)
+}
+
+// parsePostgresConfig 解析配置文件中的命名连接池
+func parsePostgresConfig(path string) (map[string]*SQLConfig, error) {
+	DBConfigMap := &PostgresMapConfig{}
+	if err := util.ParseConfig(path, DBConfigMap); err != nil {
+		return nil, err
+	}
+	list := make(map[string]*SQLConfig, len(DBConfigMap.List))
 	for configName, config := range DBConfigMap.List {
-		dialector := postgres.New(postgres.Config{
-			DSN: config.DataSourceName,
-		})
-		DBGorm, err := gorm.Open(dialector, &gorm.Config{
-			QueryFields: true,
-			Logger: logger.New(
-				log.New(os.Stdout, "\r\n", log.LstdFlags),
-				logger.Config{
-					SlowThreshold: time.Second * 2, // 满 SQL 阀值
-					LogLevel:      logLevel,        // Log Level
-					Colorful:      true,            // 禁用彩色打印
-				},
-			),
-		})
-		if err != nil {
-			return err
-		}
-		PQ, err := DBGorm.DB()
-		if err == nil {
-			PQ.SetMaxOpenConns(config.MaxOpenConn)
-			PQ.SetMaxIdleConns(config.MaxIdleConn)
-			PQ.SetConnMaxLifetime(time.Duration(config.MaxConnLifeTime) * time.Second)
-			PostgresPool[configName] = DBGorm
-		} else {
-			return err
-		}
+		list[configName] = &config.SQLConfig
 	}
-	return nil
+	return list, nil
 }
 
 // GetPgSQLPool GetGormPool 获取数据库连接
 func GetPgSQLPool(name string) (*gorm.DB, error) {
-	if db, ok := PostgresPool[name]; ok {
-		return db, nil
-	}
-	return nil, errors.New("get pool error")
+	return getSQLPool(postgresDriver, name)
+}
+
+// PgSQLPools 获取所有已连接的 Postgres 连接池
+func PgSQLPools() map[string]*gorm.DB {
+	return sqlPools(postgresDriver)
+}
+
+// PgSQLReplicas Postgres 命名连接池的只读副本快照
+func PgSQLReplicas() map[string][]*sql.DB {
+	return sqlReplicas(postgresDriver)
+}
+
+// SetPgSQLPool 登记外部创建的 Postgres 连接池，db 为 nil 时移除，用于测试替身
+func SetPgSQLPool(name string, db *gorm.DB) error {
+	return setSQLPool(postgresDriver, name, db)
 }
 
 // CloseDB 关闭数据库
 func ClosePgSQLDB() error {
-	for _, pool := range PostgresPool {
-		db, err := pool.DB()
-		if err != nil {
-			return err
-		}
-		err = db.Close()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return closeSQLPools(postgresDriver)
 }
 
 // SetLogLevel 设置日志级别
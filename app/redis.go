@@ -1,8 +1,10 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/MetaverseTopDJ/Scaffold/model"
@@ -14,6 +16,9 @@ import (
 // ConfigRedisMap 全局变量
 var ConfigRedisMap *model.RedisMapConfig
 var RedisPool map[string]*redis.Pool
+var redisReplicaPools map[string]*redis.Pool // 开启 read_from_replicas 的哨兵连接池对应的从节点连接池
+var redisClusters map[string]*RedisCluster   // 集群模式的命名配置
+var redisPoolLock sync.RWMutex               // 保护以上连接池，重新加载配置时整体替换
 
 // InitRedisConfig 加载 Redis 配置
 func InitRedisConfig(path string) error {
@@ -25,47 +30,174 @@ func InitRedisConfig(path string) error {
 	if len(RedisConfigMap.List) == 0 {
 		fmt.Printf("[INFO] %s%s\n", time.Now().Format(util.DateTimeFormat), " empty redis config.")
 	}
-	RedisPool = map[string]*redis.Pool{}
+	pools, replicaPools, clusters := map[string]*redis.Pool{}, map[string]*redis.Pool{}, map[string]*RedisCluster{}
+	breakers := map[string]*redisBreaker{}
 	for configName, config := range RedisConfigMap.List {
-		dialector := &redis.Pool{
-			MaxIdle:         config.MaxIdle,   // 最大空闲连接数
-			MaxActive:       config.MaxActive, // 分配的最大连接数
-			MaxConnLifetime: time.Duration(config.ConnTimeout),
-			IdleTimeout:     time.Duration(config.IdelTimeout),
-			Wait:            true,
-			Dial: func() (redis.Conn, error) {
-				options := redis.DialDatabase(config.Db)
-				// **重要** 设置读写超时
-				readTimeout := redis.DialReadTimeout(time.Second * time.Duration(config.ReadTimeout))
-				writeTimeout := redis.DialReadTimeout(time.Second * time.Duration(config.WriteTimeout))
-				conTimeout := redis.DialConnectTimeout(time.Second * time.Duration(config.ConnTimeout))
-				c, err := redis.Dial("tcp", config.ProxyList, options, readTimeout, writeTimeout, conTimeout)
-				if err != nil {
-					panic(err.Error())
-				}
-				return c, err
-			},
+		if len(config.ClusterNodes) > 0 {
+			clusters[configName] = NewRedisCluster(config)
+			continue
 		}
-		RedisPool[configName] = dialector
+		var pool *redis.Pool
+		if len(config.Sentinels) > 0 {
+			sentinel := newRedisSentinel(config)
+			pool = checkOnBorrow(newRedisPool(config, sentinel.dialMaster))
+			if config.ReadFromReplicas {
+				replicaPools[configName] = checkOnBorrow(newRedisPool(config, sentinel.dialReplica))
+			}
+		} else {
+			pool = newRedisPool(config, func(ctx context.Context) (redis.Conn, error) {
+				return dialRedis(ctx, config, config.ProxyList)
+			})
+		}
+		breakers[configName] = newRedisBreaker(configName, config.BreakerThreshold, time.Duration(config.BreakerTimeout)*time.Second)
+		withBreaker(pool, breakers[configName])
+		pools[configName] = pool
 	}
+	redisPoolLock.Lock()
+	ConfigRedisMap = RedisConfigMap
+	RedisPool, redisReplicaPools, redisClusters = pools, replicaPools, clusters
+	redisPoolLock.Unlock()
+	redisBreakerLock.Lock()
+	redisBreakers = breakers
+	redisBreakerLock.Unlock()
 	return nil
 }
 
+// newRedisPool 根据配置创建连接池
+func newRedisPool(config *model.RedisConfig, dial func(ctx context.Context) (redis.Conn, error)) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:         config.MaxIdle,                                      // 最大空闲连接数
+		MaxActive:       config.MaxActive,                                    // 分配的最大连接数
+		MaxConnLifetime: time.Duration(config.MaxConnLifetime) * time.Second, // 连接最长使用时间
+		IdleTimeout:     time.Duration(config.IdelTimeout) * time.Second,     // 空闲连接超时
+		Wait:            true,
+		TestOnBorrow:    healthCheck(time.Duration(config.HealthCheckInterval) * time.Second),
+		DialContext:     dial,
+	}
+}
+
+// dialRedis 连接 Redis 并完成认证与选库
+func dialRedis(ctx context.Context, config *model.RedisConfig, address string) (redis.Conn, error) {
+	options := []redis.DialOption{
+		redis.DialDatabase(config.Db),
+		// **重要** 设置读写超时
+		redis.DialReadTimeout(time.Second * time.Duration(config.ReadTimeout)),
+		redis.DialWriteTimeout(time.Second * time.Duration(config.WriteTimeout)),
+		redis.DialConnectTimeout(time.Second * time.Duration(config.ConnTimeout)),
+	}
+	if config.Username != "" {
+		options = append(options, redis.DialUsername(config.Username))
+	}
+	if config.Password != "" {
+		options = append(options, redis.DialPassword(config.Password))
+	}
+	c, err := redis.DialContext(ctx, "tcp", address, options...)
+	if err != nil {
+		return nil, fmt.Errorf("dial redis %s failed: %w", address, err)
+	}
+	return c, nil
+}
+
 // GetRedisPool 获取 Redis 数据库连接
 func GetRedisPool(name string) (*redis.Pool, error) {
-	if pool, ok := RedisPool[name]; ok {
+	redisPoolLock.RLock()
+	pool, ok := RedisPool[name]
+	redisPoolLock.RUnlock()
+	if ok {
 		return pool, nil
 	}
 	return nil, errors.New("GetRedisPoolError") // 获取 Redis 连接池错误
 }
 
+// GetRedisReplicaPool 获取哨兵模式下的从节点连接池，未开启 read_from_replicas 时返回主节点连接池
+func GetRedisReplicaPool(name string) (*redis.Pool, error) {
+	redisPoolLock.RLock()
+	pool, ok := redisReplicaPools[name]
+	redisPoolLock.RUnlock()
+	if ok {
+		return pool, nil
+	}
+	return GetRedisPool(name)
+}
+
+// GetRedisCluster 获取集群模式的 Redis 命名配置对应的集群客户端
+func GetRedisCluster(name string) (*RedisCluster, error) {
+	redisPoolLock.RLock()
+	cluster, ok := redisClusters[name]
+	redisPoolLock.RUnlock()
+	if ok {
+		return cluster, nil
+	}
+	return nil, errors.New("GetRedisClusterError") // 获取 Redis 集群错误
+}
+
+// GetRedisClient 获取 Redis 命名客户端，键自动加上配置的前缀
+func GetRedisClient(name string) (*RedisClient, error) {
+	redisPoolLock.RLock()
+	var prefix string
+	if ConfigRedisMap != nil {
+		if config, ok := ConfigRedisMap.List[name]; ok {
+			prefix = config.Prefix
+		}
+	}
+	cluster, isCluster := redisClusters[name]
+	pool, ok := RedisPool[name]
+	replica := redisReplicaPools[name]
+	redisPoolLock.RUnlock()
+	if isCluster {
+		return NewRedisClusterClient(cluster, prefix), nil
+	}
+	if !ok {
+		return nil, errors.New("GetRedisPoolError") // 获取 Redis 连接池错误
+	}
+	client := NewRedisClient(pool, prefix)
+	client.replica = replica
+	return client, nil
+}
+
+// RedisPools Redis 命名连接池快照，不含集群模式的配置
+func RedisPools() map[string]*redis.Pool {
+	redisPoolLock.RLock()
+	defer redisPoolLock.RUnlock()
+	pools := make(map[string]*redis.Pool, len(RedisPool))
+	for name, pool := range RedisPool {
+		pools[name] = pool
+	}
+	return pools
+}
+
+// RedisReplicaPools 开启 read_from_replicas 的哨兵连接池对应的从节点连接池快照
+func RedisReplicaPools() map[string]*redis.Pool {
+	redisPoolLock.RLock()
+	defer redisPoolLock.RUnlock()
+	pools := make(map[string]*redis.Pool, len(redisReplicaPools))
+	for name, pool := range redisReplicaPools {
+		pools[name] = pool
+	}
+	return pools
+}
+
 // CloseRedisDB 关闭 Redis 数据库
 func CloseRedisDB() error {
+	redisPoolLock.RLock()
+	defer redisPoolLock.RUnlock()
 	for _, pool := range RedisPool {
 		err := pool.Close()
 		if err != nil {
 			return err
 		}
 	}
+	for _, pool := range redisReplicaPools {
+		err := pool.Close()
+		if err != nil {
+			return err
+		}
+	}
+	for _, cluster := range redisClusters {
+		err := cluster.Close()
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
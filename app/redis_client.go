@@ -0,0 +1,186 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisClient 命名 Redis 连接池的客户端，键自动加上前缀，命令遵循上下文的截止时间
+type RedisClient struct {
+	pool    *redis.Pool
+	replica *redis.Pool // 从节点连接池，为空时读命令也发送到主节点
+	cluster *RedisCluster
+	prefix  string
+}
+
+// NewRedisClient 创建 Redis 客户端
+func NewRedisClient(pool *redis.Pool, prefix string) *RedisClient {
+	return &RedisClient{pool: pool, prefix: prefix}
+}
+
+// NewRedisClusterClient 创建 Redis 集群客户端
+func NewRedisClusterClient(cluster *RedisCluster, prefix string) *RedisClient {
+	return &RedisClient{cluster: cluster, prefix: prefix}
+}
+
+// Pool 底层连接池，集群模式下为 nil
+func (c *RedisClient) Pool() *redis.Pool {
+	return c.pool
+}
+
+// Cluster 集群客户端，非集群模式下为 nil
+func (c *RedisClient) Cluster() *RedisCluster {
+	return c.cluster
+}
+
+// Key 加上前缀的键
+func (c *RedisClient) Key(key string) string {
+	return c.prefix + key
+}
+
+// keys 为多个键加上前缀
+func (c *RedisClient) keys(keys []string) []interface{} {
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = c.prefix + key
+	}
+	return args
+}
+
+// Conn 获取连接，使用后需关闭；通过连接执行的命令不会自动加前缀，集群模式下的连接不支持管道
+func (c *RedisClient) Conn(ctx context.Context) (redis.Conn, error) {
+	if c.cluster != nil {
+		return c.cluster.Conn(ctx), nil
+	}
+	return c.pool.GetContext(ctx)
+}
+
+// Do 执行原始命令，参数中的键不会自动加前缀
+func (c *RedisClient) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := c.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+// read 执行读命令，有从节点连接池且未通过 WithPrimary 强制主节点时发送到从节点
+func (c *RedisClient) read(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if c.replica == nil || IsForcePrimary(ctx) {
+		return c.Do(ctx, cmd, args...)
+	}
+	conn, err := c.replica.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+// Get 获取字符串，键不存在时返回 redis.ErrNil
+func (c *RedisClient) Get(ctx context.Context, key string) (string, error) {
+	return redis.String(c.read(ctx, "GET", c.Key(key)))
+}
+
+// GetBytes 获取字节，键不存在时返回 redis.ErrNil
+func (c *RedisClient) GetBytes(ctx context.Context, key string) ([]byte, error) {
+	return redis.Bytes(c.read(ctx, "GET", c.Key(key)))
+}
+
+// Set 设置值，ttl 为 0 时不过期
+func (c *RedisClient) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	args := redis.Args{c.Key(key), value}
+	if ttl > 0 {
+		args = args.Add("PX", ttl.Milliseconds())
+	}
+	_, err := c.Do(ctx, "SET", args...)
+	return err
+}
+
+// SetNX 键不存在时设置值，返回是否设置成功
+func (c *RedisClient) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	args := redis.Args{c.Key(key), value, "NX"}
+	if ttl > 0 {
+		args = args.Add("PX", ttl.Milliseconds())
+	}
+	reply, err := c.Do(ctx, "SET", args...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// MGet 批量获取，不存在的键对应空字符串
+func (c *RedisClient) MGet(ctx context.Context, keys ...string) ([]string, error) {
+	return redis.Strings(c.read(ctx, "MGET", c.keys(keys)...))
+}
+
+// Del 删除键，返回删除的数量
+func (c *RedisClient) Del(ctx context.Context, keys ...string) (int, error) {
+	return redis.Int(c.Do(ctx, "DEL", c.keys(keys)...))
+}
+
+// Exists 键是否存在
+func (c *RedisClient) Exists(ctx context.Context, key string) (bool, error) {
+	return redis.Bool(c.read(ctx, "EXISTS", c.Key(key)))
+}
+
+// Expire 设置过期时间，返回键是否存在
+func (c *RedisClient) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return redis.Bool(c.Do(ctx, "PEXPIRE", c.Key(key), ttl.Milliseconds()))
+}
+
+// TTL 剩余过期时间，键不存在时返回 -2ms，未设置过期时间时返回 -1ms (与 PTTL 一致)
+func (c *RedisClient) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ms, err := redis.Int64(c.read(ctx, "PTTL", c.Key(key)))
+	return time.Duration(ms) * time.Millisecond, err
+}
+
+// Incr 自增 1
+func (c *RedisClient) Incr(ctx context.Context, key string) (int64, error) {
+	return redis.Int64(c.Do(ctx, "INCR", c.Key(key)))
+}
+
+// IncrBy 自增 n
+func (c *RedisClient) IncrBy(ctx context.Context, key string, n int64) (int64, error) {
+	return redis.Int64(c.Do(ctx, "INCRBY", c.Key(key), n))
+}
+
+// HGet 获取哈希字段，不存在时返回 redis.ErrNil
+func (c *RedisClient) HGet(ctx context.Context, key, field string) (string, error) {
+	return redis.String(c.read(ctx, "HGET", c.Key(key), field))
+}
+
+// HSet 设置哈希字段
+func (c *RedisClient) HSet(ctx context.Context, key, field string, value interface{}) error {
+	_, err := c.Do(ctx, "HSET", c.Key(key), field, value)
+	return err
+}
+
+// HGetAll 获取所有哈希字段
+func (c *RedisClient) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return redis.StringMap(c.read(ctx, "HGETALL", c.Key(key)))
+}
+
+// HDel 删除哈希字段，返回删除的数量
+func (c *RedisClient) HDel(ctx context.Context, key string, fields ...string) (int, error) {
+	return redis.Int(c.Do(ctx, "HDEL", redis.Args{c.Key(key)}.AddFlat(fields)...))
+}
+
+// Publish 发布消息，频道同样加上前缀，返回接收者数量
+func (c *RedisClient) Publish(ctx context.Context, channel string, message interface{}) (int, error) {
+	return redis.Int(c.Do(ctx, "PUBLISH", c.Key(channel), message))
+}
+
+// Eval 执行 Lua 脚本，keys 自动加上前缀
+func (c *RedisClient) Eval(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	conn, err := c.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return script.DoContext(ctx, conn, append(c.keys(keys), args...)...)
+}
@@ -0,0 +1,501 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+	"github.com/MetaverseTopDJ/Scaffold/model"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisSlots 集群哈希槽数量
+const RedisSlots = 16384
+
+// 集群重定向参数
+const (
+	redisClusterRedirects = 5                      // 单个命令最多重定向次数
+	redisClusterRetryWait = time.Millisecond * 100 // TRYAGAIN / CLUSTERDOWN 后的等待时间
+)
+
+var ErrRedisClusterPipeline = errors.New("redis cluster connection does not support pipelining")
+
+// RedisCluster Redis 集群客户端：按哈希槽路由命令，处理 MOVED / ASK 重定向，多键命令按槽拆分
+type RedisCluster struct {
+	config *model.RedisConfig
+	seeds  []string
+
+	mu    sync.RWMutex
+	slots [RedisSlots]string     // 槽对应的主节点地址
+	pools map[string]*redis.Pool // 节点地址对应的连接池
+
+	refreshing int32 // 后台刷新槽位表中
+}
+
+// NewRedisCluster 创建集群客户端，首次执行命令时读取槽位表
+func NewRedisCluster(config *model.RedisConfig) *RedisCluster {
+	return &RedisCluster{config: config, seeds: config.ClusterNodes, pools: map[string]*redis.Pool{}}
+}
+
+// RedisSlot 键对应的哈希槽，键中包含 {tag} 时只计算 tag
+func RedisSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % RedisSlots)
+}
+
+// crc16 CRC16-XMODEM
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Nodes 当前已知的主节点地址
+func (c *RedisCluster) Nodes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	seen := map[string]bool{}
+	var nodes []string
+	for _, addr := range c.slots {
+		if addr != "" && !seen[addr] {
+			seen[addr] = true
+			nodes = append(nodes, addr)
+		}
+	}
+	return nodes
+}
+
+// Refresh 依次向已知节点与种子节点执行 CLUSTER SLOTS 更新槽位表
+func (c *RedisCluster) Refresh(ctx context.Context) error {
+	candidates := append(c.Nodes(), c.seeds...)
+	var errs []string
+	for _, addr := range candidates {
+		slots, err := c.querySlots(ctx, addr)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", addr, err))
+			continue
+		}
+		c.setSlots(slots)
+		return nil
+	}
+	return fmt.Errorf("redis cluster refresh failed: %s", strings.Join(errs, "; "))
+}
+
+// querySlots 向节点查询槽位表
+func (c *RedisCluster) querySlots(ctx context.Context, addr string) (*[RedisSlots]string, error) {
+	conn, err := c.pool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ranges, err := redis.Values(redis.DoContext(conn, ctx, "CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	slots := &[RedisSlots]string{}
+	for _, r := range ranges {
+		fields, err := redis.Values(r, nil)
+		if err != nil || len(fields) < 3 {
+			return nil, fmt.Errorf("unexpected CLUSTER SLOTS entry %v", r)
+		}
+		start, _ := redis.Int(fields[0], nil)
+		end, _ := redis.Int(fields[1], nil)
+		node, err := redis.Values(fields[2], nil)
+		if err != nil || len(node) < 2 || start < 0 || end >= RedisSlots {
+			return nil, fmt.Errorf("unexpected CLUSTER SLOTS entry %v", r)
+		}
+		ip, _ := redis.String(node[0], nil)
+		port, _ := redis.Int(node[1], nil)
+		if ip == "" { // 空地址表示与被查询的节点相同
+			ip = host
+		}
+		master := net.JoinHostPort(ip, strconv.Itoa(port))
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = master
+		}
+	}
+	return slots, nil
+}
+
+// setSlots 替换槽位表，关闭已不再负责任何槽的节点连接池
+func (c *RedisCluster) setSlots(slots *[RedisSlots]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slots = *slots
+	used := map[string]bool{}
+	for _, addr := range c.slots {
+		used[addr] = true
+	}
+	for addr, pool := range c.pools {
+		if !used[addr] && !c.isSeed(addr) {
+			_ = pool.Close()
+			delete(c.pools, addr)
+		}
+	}
+}
+
+func (c *RedisCluster) isSeed(addr string) bool {
+	for _, seed := range c.seeds {
+		if seed == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// refreshAsync 收到 MOVED 后在后台刷新槽位表，同一时间只刷新一次
+func (c *RedisCluster) refreshAsync() {
+	if !atomic.CompareAndSwapInt32(&c.refreshing, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&c.refreshing, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), defaultVerifyTimeout)
+		defer cancel()
+		if err := c.Refresh(ctx); err != nil {
+			logger.Warn("%v", err)
+		}
+	}()
+}
+
+// pool 节点连接池，不存在时创建
+func (c *RedisCluster) pool(addr string) *redis.Pool {
+	c.mu.RLock()
+	pool, ok := c.pools[addr]
+	c.mu.RUnlock()
+	if ok {
+		return pool
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pool, ok := c.pools[addr]; ok {
+		return pool
+	}
+	pool = newRedisPool(c.config, func(ctx context.Context) (redis.Conn, error) {
+		return dialRedis(ctx, c.config, addr)
+	})
+	c.pools[addr] = pool
+	return pool
+}
+
+// nodeFor 槽对应的节点地址，槽位表为空时先刷新；slot 小于 0 时任选一个节点
+func (c *RedisCluster) nodeFor(ctx context.Context, slot int) (string, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		c.mu.RLock()
+		var addr string
+		if slot >= 0 {
+			addr = c.slots[slot]
+		} else {
+			for _, a := range c.slots {
+				if a != "" {
+					addr = a
+					break
+				}
+			}
+		}
+		c.mu.RUnlock()
+		if addr != "" {
+			return addr, nil
+		}
+		if attempt == 0 {
+			if err := c.Refresh(ctx); err != nil {
+				return "", err
+			}
+		}
+	}
+	return "", fmt.Errorf("redis cluster slot %d not covered", slot)
+}
+
+// Do 执行命令，按第一个键路由；DEL / EXISTS / UNLINK / TOUCH / MGET / MSET 的键分属多个槽时拆分执行后合并结果
+func (c *RedisCluster) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	name := strings.ToUpper(cmd)
+	switch name {
+	case "DEL", "EXISTS", "UNLINK", "TOUCH", "MGET":
+		if groups := groupBySlot(args, 1); len(groups) > 1 {
+			return c.doSplit(ctx, name, args, groups, 1)
+		}
+	case "MSET":
+		if groups := groupBySlot(args, 2); len(groups) > 1 {
+			return c.doSplit(ctx, name, args, groups, 2)
+		}
+	}
+	return c.doKey(ctx, name, commandSlot(name, args), args)
+}
+
+// doKey 向槽所在节点执行命令，处理重定向
+func (c *RedisCluster) doKey(ctx context.Context, cmd string, slot int, args []interface{}) (interface{}, error) {
+	addr, err := c.nodeFor(ctx, slot)
+	if err != nil {
+		return nil, err
+	}
+	asking, refreshed := false, false
+	for redirects := 0; ; redirects++ {
+		reply, err := c.doNode(ctx, addr, asking, cmd, args)
+		asking = false
+		if err == nil || redirects >= redisClusterRedirects {
+			return reply, err
+		}
+		replyErr, ok := err.(redis.Error)
+		if !ok {
+			if refreshed || ctx.Err() != nil {
+				return reply, err
+			}
+			// 网络错误：节点可能已下线，刷新槽位表后重试一次
+			refreshed = true
+			if c.Refresh(ctx) != nil {
+				return reply, err
+			}
+			if addr, err = c.nodeFor(ctx, slot); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		msg := string(replyErr)
+		switch {
+		case strings.HasPrefix(msg, "MOVED "):
+			target, movedSlot, ok := parseRedirect(msg)
+			if !ok {
+				return reply, err
+			}
+			c.mu.Lock()
+			c.slots[movedSlot] = target
+			c.mu.Unlock()
+			c.refreshAsync()
+			addr = target
+		case strings.HasPrefix(msg, "ASK "):
+			target, _, ok := parseRedirect(msg)
+			if !ok {
+				return reply, err
+			}
+			addr, asking = target, true
+		case strings.HasPrefix(msg, "TRYAGAIN"), strings.HasPrefix(msg, "CLUSTERDOWN"):
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(redisClusterRetryWait):
+			}
+		default:
+			return reply, err
+		}
+	}
+}
+
+// doNode 在指定节点上执行命令，asking 为 true 时先发送 ASKING
+func (c *RedisCluster) doNode(ctx context.Context, addr string, asking bool, cmd string, args []interface{}) (interface{}, error) {
+	conn, err := c.pool(addr).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if asking {
+		if _, err := redis.DoContext(conn, ctx, "ASKING"); err != nil {
+			return nil, err
+		}
+	}
+	return redis.DoContext(conn, ctx, cmd, args...)
+}
+
+// slotGroup 同一个槽的参数及其在原参数中的位置
+type slotGroup struct {
+	slot    int
+	args    []interface{}
+	indexes []int // 键的序号
+}
+
+// groupBySlot 按槽对键分组，step 为每个键占用的参数个数
+func groupBySlot(args []interface{}, step int) []*slotGroup {
+	var groups []*slotGroup
+	bySlot := map[int]*slotGroup{}
+	for i := 0; i+step <= len(args); i += step {
+		slot := RedisSlot(argString(args[i]))
+		group, ok := bySlot[slot]
+		if !ok {
+			group = &slotGroup{slot: slot}
+			bySlot[slot] = group
+			groups = append(groups, group)
+		}
+		group.args = append(group.args, args[i:i+step]...)
+		group.indexes = append(group.indexes, i/step)
+	}
+	return groups
+}
+
+// doSplit 按槽拆分执行多键命令并合并结果
+func (c *RedisCluster) doSplit(ctx context.Context, cmd string, args []interface{}, groups []*slotGroup, step int) (interface{}, error) {
+	type result struct {
+		reply interface{}
+		err   error
+	}
+	results := make([]result, len(groups))
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		wg.Add(1)
+		go func(i int, group *slotGroup) {
+			defer wg.Done()
+			reply, err := c.doKey(ctx, cmd, group.slot, group.args)
+			results[i] = result{reply, err}
+		}(i, group)
+	}
+	wg.Wait()
+	for _, r := range results {
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+	switch cmd {
+	case "MGET":
+		values := make([]interface{}, len(args)/step)
+		for i, group := range groups {
+			reply, err := redis.Values(results[i].reply, nil)
+			if err != nil {
+				return nil, err
+			}
+			for j, index := range group.indexes {
+				if j < len(reply) {
+					values[index] = reply[j]
+				}
+			}
+		}
+		return values, nil
+	case "MSET":
+		return "OK", nil
+	default: // DEL / EXISTS / UNLINK / TOUCH 返回数量之和
+		var total int64
+		for _, r := range results {
+			n, err := redis.Int64(r.reply, nil)
+			if err != nil {
+				return nil, err
+			}
+			total += n
+		}
+		return total, nil
+	}
+}
+
+// commandSlot 命令的路由槽，没有键的命令返回 -1
+func commandSlot(cmd string, args []interface{}) int {
+	switch cmd {
+	case "PING", "INFO", "TIME", "ECHO", "DBSIZE", "SCRIPT", "CLUSTER", "PUBLISH":
+		return -1
+	case "EVAL", "EVALSHA":
+		if len(args) > 2 {
+			if n, err := redis.Int(args[1], nil); err == nil && n > 0 {
+				return RedisSlot(argString(args[2]))
+			}
+		}
+		return -1
+	}
+	if len(args) == 0 {
+		return -1
+	}
+	return RedisSlot(argString(args[0]))
+}
+
+// argString 命令参数转为字符串
+func argString(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(arg)
+}
+
+// parseRedirect 解析 MOVED / ASK 错误中的槽与目标地址
+func parseRedirect(msg string) (addr string, slot int, ok bool) {
+	fields := strings.Fields(msg)
+	if len(fields) != 3 {
+		return "", 0, false
+	}
+	slot, err := strconv.Atoi(fields[1])
+	if err != nil || slot < 0 || slot >= RedisSlots {
+		return "", 0, false
+	}
+	return fields[2], slot, true
+}
+
+// Conn 返回按键路由的连接，可用于 redis.Script 等需要 redis.Conn 的场景；不支持 Send / Receive 管道
+func (c *RedisCluster) Conn(ctx context.Context) redis.Conn {
+	return &clusterConn{cluster: c, ctx: ctx}
+}
+
+// Close 关闭所有节点连接池
+func (c *RedisCluster) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []string
+	for addr, pool := range c.pools {
+		if err := pool.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", addr, err))
+		}
+		delete(c.pools, addr)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// clusterConn 将每条命令交给集群路由的连接
+type clusterConn struct {
+	cluster *RedisCluster
+	ctx     context.Context
+}
+
+func (c *clusterConn) Close() error { return nil }
+func (c *clusterConn) Err() error   { return nil }
+
+func (c *clusterConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		return nil, nil
+	}
+	return c.cluster.Do(c.ctx, cmd, args...)
+}
+
+func (c *clusterConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	if cmd == "" {
+		return nil, nil
+	}
+	return c.cluster.Do(ctx, cmd, args...)
+}
+
+func (c *clusterConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, timeout)
+	defer cancel()
+	return c.DoContext(ctx, cmd, args...)
+}
+
+func (c *clusterConn) Send(cmd string, args ...interface{}) error { return ErrRedisClusterPipeline }
+func (c *clusterConn) Flush() error                               { return ErrRedisClusterPipeline }
+func (c *clusterConn) Receive() (interface{}, error)              { return nil, ErrRedisClusterPipeline }
+
+func (c *clusterConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	return nil, ErrRedisClusterPipeline
+}
+
+func (c *clusterConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return nil, ErrRedisClusterPipeline
+}
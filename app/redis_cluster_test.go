@@ -0,0 +1,161 @@
+package app_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/apptest"
+	"github.com/MetaverseTopDJ/Scaffold/model"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestRedisSlot(t *testing.T) {
+	for key, want := range map[string]int{
+		"123456789":     12739, // CRC16-XMODEM 校验值 0x31C3
+		"foo":           12182,
+		"bar":           5061,
+		"hello":         866,
+		"somekey":       11058,
+		"foo{hash_tag}": 2515,
+		"{foo}.bar":     12182,
+		"foo{}{bar}":    8363, // 空 tag 时计算整个键
+		"foo{{bar}}zap": 4015, // tag 为 "{bar"
+		"foo{bar}{zap}": 5061, // 只取第一个 tag
+	} {
+		if got := app.RedisSlot(key); got != want {
+			t.Errorf("RedisSlot(%q) = %d, want %d", key, got, want)
+		}
+	}
+	if app.RedisSlot("{user1000}.following") != app.RedisSlot("{user1000}.followers") {
+		t.Error("keys with the same hash tag in different slots")
+	}
+}
+
+// newCluster 启动 3 个节点的进程内集群并创建客户端
+func newCluster(t *testing.T) (*apptest.RedisCluster, *app.RedisClient) {
+	t.Helper()
+	nodes, err := apptest.NewRedisCluster(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = nodes.Close() })
+	cluster := app.NewRedisCluster(&model.RedisConfig{ClusterNodes: nodes.Addrs()})
+	t.Cleanup(func() { _ = cluster.Close() })
+	if err := cluster.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return nodes, app.NewRedisClusterClient(cluster, "")
+}
+
+// otherNode 不负责 key 的任一节点
+func otherNode(nodes *apptest.RedisCluster, key string) *apptest.RedisServer {
+	owner := nodes.NodeOf(key)
+	for _, node := range nodes.Nodes {
+		if node != owner {
+			return node
+		}
+	}
+	return nil
+}
+
+func TestRedisClusterMoved(t *testing.T) {
+	nodes, client := newCluster(t)
+	ctx := context.Background()
+	if err := client.Set(ctx, "foo", "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	from, to := nodes.NodeOf("foo"), otherNode(nodes, "foo")
+	nodes.MoveSlot(app.RedisSlot("foo"), to)
+
+	conn, err := redis.Dial("tcp", from.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	want := fmt.Sprintf("MOVED %d %s", app.RedisSlot("foo"), to.Addr())
+	if _, err := conn.Do("GET", "foo"); err == nil || err.Error() != want {
+		t.Fatalf("old node replied %v, want %s", err, want)
+	}
+
+	if v, err := client.Get(ctx, "foo"); err != nil || v != "1" {
+		t.Fatalf("Get after MOVED = %q, %v", v, err)
+	}
+	if err := client.Set(ctx, "foo", "2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if keys := to.Keys(0); len(keys) != 1 || keys[0] != "foo" {
+		t.Fatalf("new owner keys = %v", keys)
+	}
+}
+
+func TestRedisClusterAsk(t *testing.T) {
+	nodes, client := newCluster(t)
+	ctx := context.Background()
+	if err := client.Set(ctx, "{user1}.old", "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	from, to := nodes.NodeOf("{user1}.old"), otherNode(nodes, "{user1}.old")
+	nodes.MigrateSlot(app.RedisSlot("{user1}"), to)
+
+	// 已存在的键仍由源节点处理，不存在的键通过 ASK 写入目标节点
+	if v, err := client.Get(ctx, "{user1}.old"); err != nil || v != "1" {
+		t.Fatalf("Get during migration = %q, %v", v, err)
+	}
+	if err := client.Set(ctx, "{user1}.new", "2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if keys := to.Keys(0); len(keys) != 1 || keys[0] != "{user1}.new" {
+		t.Fatalf("target keys = %v", keys)
+	}
+	if keys := from.Keys(0); len(keys) != 1 || keys[0] != "{user1}.old" {
+		t.Fatalf("source keys = %v", keys)
+	}
+	// ASK 不更新槽位表，读取仍先发送到源节点再重定向
+	if v, err := client.Get(ctx, "{user1}.new"); err != nil || v != "2" {
+		t.Fatalf("Get migrated key = %q, %v", v, err)
+	}
+}
+
+func TestRedisClusterMultiKey(t *testing.T) {
+	nodes, client := newCluster(t)
+	ctx := context.Background()
+	keys := make([]string, 10)
+	args := make([]interface{}, 0, len(keys)*2)
+	owners := map[*apptest.RedisServer]bool{}
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+		args = append(args, keys[i], fmt.Sprintf("value%d", i))
+		owners[nodes.NodeOf(keys[i])] = true
+	}
+	if len(owners) < 2 {
+		t.Fatal("keys not spread over nodes")
+	}
+	if _, err := client.Do(ctx, "MSET", args...); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := client.MGet(ctx, append([]string{"missing"}, keys...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != len(keys)+1 || values[0] != "" {
+		t.Fatalf("MGet = %q", values)
+	}
+	for i, key := range keys {
+		if want := fmt.Sprintf("value%d", i); values[i+1] != want {
+			t.Fatalf("MGet %s = %q, want %q", key, values[i+1], want)
+		}
+	}
+
+	n, err := client.Del(ctx, append(keys[:5:5], "missing")...)
+	if err != nil || n != 5 {
+		t.Fatalf("Del = %d, %v, want 5", n, err)
+	}
+	n, err = redis.Int(client.Do(ctx, "EXISTS", redis.Args{}.AddFlat(keys)...))
+	if err != nil || n != 5 {
+		t.Fatalf("Exists = %d, %v, want 5", n, err)
+	}
+}
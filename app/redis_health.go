@@ -0,0 +1,253 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    // 正常
+	BreakerOpen     = "open"      // 熔断中，快速失败
+	BreakerHalfOpen = "half-open" // 探测恢复中
+)
+
+// 健康检查与熔断默认参数
+const (
+	defaultRedisHealthCheck     = time.Minute
+	defaultRedisBreakerFailures = 5
+	defaultRedisBreakerTimeout  = time.Second * 10
+)
+
+var ErrRedisCircuitOpen = errors.New("redis circuit breaker is open")
+
+// redisBreaker 连接池熔断器，连续失败达到阀值后熔断，超时后放行一次探测
+type redisBreaker struct {
+	name      string
+	threshold int
+	timeout   time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	changedAt time.Time // 进入当前状态或开始探测的时间
+	probing   bool
+}
+
+var (
+	redisBreakerLock sync.RWMutex
+	redisBreakers    = map[string]*redisBreaker{}
+)
+
+// newRedisBreaker 创建熔断器，threshold 小于 0 时不启用
+func newRedisBreaker(name string, threshold int, timeout time.Duration) *redisBreaker {
+	if threshold < 0 {
+		return nil
+	}
+	if threshold == 0 {
+		threshold = defaultRedisBreakerFailures
+	}
+	if timeout <= 0 {
+		timeout = defaultRedisBreakerTimeout
+	}
+	return &redisBreaker{name: name, threshold: threshold, timeout: timeout, state: BreakerClosed, changedAt: time.Now()}
+}
+
+// GetRedisBreakerState 获取 Redis 命名连接池的熔断器状态
+func GetRedisBreakerState(name string) (string, bool) {
+	redisBreakerLock.RLock()
+	b, ok := redisBreakers[name]
+	redisBreakerLock.RUnlock()
+	if !ok || b == nil {
+		return "", false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state, true
+}
+
+// allow 是否放行请求，probe 表示本次请求用于探测恢复
+func (b *redisBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.changedAt) < b.timeout {
+			return false, ErrRedisCircuitOpen
+		}
+		b.setState(BreakerHalfOpen, nil)
+		b.probing = true
+		return true, nil
+	case BreakerHalfOpen:
+		if b.probing && time.Since(b.changedAt) < b.timeout {
+			return false, ErrRedisCircuitOpen
+		}
+		b.probing, b.changedAt = true, time.Now() // 上次探测没有结果，重新探测
+		return true, nil
+	}
+	return false, nil
+}
+
+// report 记录命令结果，服务端返回的错误视为连接正常
+func (b *redisBreaker) report(err error) {
+	var replyErr redis.Error
+	if err == nil || err == redis.ErrNil || errors.As(err, &replyErr) {
+		b.success()
+		return
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrRedisCircuitOpen) {
+		return
+	}
+	b.failure(err)
+}
+
+func (b *redisBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if b.state != BreakerClosed {
+		b.probing = false
+		b.setState(BreakerClosed, nil)
+	}
+}
+
+func (b *redisBreaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	switch {
+	case b.state == BreakerHalfOpen:
+		b.probing = false
+		b.setState(BreakerOpen, err)
+	case b.state == BreakerClosed && b.failures >= b.threshold:
+		b.setState(BreakerOpen, err)
+	}
+}
+
+// setState 切换状态并记录日志，调用方需持有 b.mu
+func (b *redisBreaker) setState(state string, err error) {
+	from := b.state
+	b.state, b.changedAt = state, time.Now()
+	switch state {
+	case BreakerOpen:
+		logger.Error("redis pool %s circuit %s -> %s after %d failure(s): %v", b.name, from, state, b.failures, err)
+		setPoolStatus("redis", b.name, PoolFailed, b.failures, err)
+	case BreakerHalfOpen:
+		logger.Warn("redis pool %s circuit %s -> %s, probing", b.name, from, state)
+	case BreakerClosed:
+		logger.Info("redis pool %s circuit %s -> %s, recovered", b.name, from, state)
+		setPoolStatus("redis", b.name, PoolReady, 0, nil)
+	}
+}
+
+// reportConn 将命令结果交给 report 的连接
+type reportConn struct {
+	redis.Conn
+	report func(err error)
+	check  func() error // 不为空时附加连接可用性检查，返回错误时连接池丢弃该连接
+}
+
+// Err 实现 redis.Conn
+func (c *reportConn) Err() error {
+	if err := c.Conn.Err(); err != nil {
+		return err
+	}
+	if c.check != nil {
+		return c.check()
+	}
+	return nil
+}
+
+func (c *reportConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(cmd, args...)
+	if cmd != "" { // 连接池归还连接时的 flush 不计入
+		c.report(err)
+	}
+	return reply, err
+}
+
+func (c *reportConn) DoWithTimeout(timeout time.Duration, cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := redis.DoWithTimeout(c.Conn, timeout, cmd, args...)
+	c.report(err)
+	return reply, err
+}
+
+func (c *reportConn) DoContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := redis.DoContext(c.Conn, ctx, cmd, args...)
+	c.report(err)
+	return reply, err
+}
+
+func (c *reportConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.report(err)
+	return reply, err
+}
+
+func (c *reportConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := redis.ReceiveWithTimeout(c.Conn, timeout)
+	c.report(err)
+	return reply, err
+}
+
+func (c *reportConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	reply, err := redis.ReceiveContext(c.Conn, ctx)
+	c.report(err)
+	return reply, err
+}
+
+// withBreaker 为连接池加上熔断：熔断期间借出与新建连接直接失败
+func withBreaker(pool *redis.Pool, b *redisBreaker) {
+	if b == nil {
+		return
+	}
+	dial, testOnBorrow := pool.DialContext, pool.TestOnBorrow
+	pool.DialContext = func(ctx context.Context) (redis.Conn, error) {
+		if _, err := b.allow(); err != nil {
+			return nil, err
+		}
+		c, err := dial(ctx)
+		if err != nil {
+			b.report(err)
+			return nil, err
+		}
+		return &reportConn{Conn: c, report: b.report}, nil // 连接成功不代表可用，由后续命令结果决定
+	}
+	pool.TestOnBorrow = func(c redis.Conn, t time.Time) error {
+		probe, err := b.allow()
+		if err != nil {
+			return err
+		}
+		if probe {
+			_, err := c.Do("PING") // 结果由 reportConn 报告
+			return err
+		}
+		if testOnBorrow != nil {
+			return testOnBorrow(c, t)
+		}
+		return nil
+	}
+}
+
+// healthCheck 借出空闲超过 interval 的连接前先 PING，interval 小于 0 时不检查
+func healthCheck(interval time.Duration) func(c redis.Conn, t time.Time) error {
+	if interval < 0 {
+		return nil
+	}
+	if interval == 0 {
+		interval = defaultRedisHealthCheck
+	}
+	return func(c redis.Conn, t time.Time) error {
+		if time.Since(t) < interval {
+			return nil
+		}
+		_, err := c.Do("PING")
+		return err
+	}
+}
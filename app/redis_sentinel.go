@@ -0,0 +1,250 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+	"github.com/MetaverseTopDJ/Scaffold/model"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var errRedisStaleMaster = errors.New("redis master changed")
+
+// redisSentinel 通过哨兵发现主从节点，主节点地址缓存到下次故障
+type redisSentinel struct {
+	config *model.RedisConfig
+
+	mu     sync.Mutex
+	addrs  []string // 哨兵地址，最近一次可用的排在最前
+	master string   // 当前主节点地址
+}
+
+func newRedisSentinel(config *model.RedisConfig) *redisSentinel {
+	return &redisSentinel{config: config, addrs: append([]string(nil), config.Sentinels...)}
+}
+
+// query 依次尝试哨兵执行 fn，成功的哨兵移到最前
+func (s *redisSentinel) query(ctx context.Context, fn func(c redis.Conn) error) error {
+	s.mu.Lock()
+	addrs := append([]string(nil), s.addrs...)
+	s.mu.Unlock()
+	options := []redis.DialOption{
+		redis.DialConnectTimeout(time.Second * time.Duration(s.config.ConnTimeout)),
+		redis.DialReadTimeout(time.Second * time.Duration(s.config.ReadTimeout)),
+		redis.DialWriteTimeout(time.Second * time.Duration(s.config.WriteTimeout)),
+	}
+	if s.config.SentinelPassword != "" {
+		options = append(options, redis.DialPassword(s.config.SentinelPassword))
+	}
+	var errs []string
+	for i, addr := range addrs {
+		c, err := redis.DialContext(ctx, "tcp", addr, options...)
+		if err == nil {
+			err = fn(c)
+			c.Close()
+		}
+		if err == nil {
+			if i > 0 {
+				s.mu.Lock()
+				s.addrs = append([]string{addr}, removeString(s.addrs, addr)...)
+				s.mu.Unlock()
+			}
+			return nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", addr, err))
+	}
+	return fmt.Errorf("redis sentinel %s: %s", s.config.MasterName, strings.Join(errs, "; "))
+}
+
+// masterAddr 当前主节点地址
+func (s *redisSentinel) masterAddr(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	master := s.master
+	s.mu.Unlock()
+	if master != "" {
+		return master, nil
+	}
+	err := s.query(ctx, func(c redis.Conn) error {
+		reply, err := redis.Strings(redis.DoContext(c, ctx, "SENTINEL", "get-master-addr-by-name", s.config.MasterName))
+		if err != nil {
+			return err
+		}
+		if len(reply) != 2 {
+			return fmt.Errorf("unexpected reply %v", reply)
+		}
+		master = net.JoinHostPort(reply[0], reply[1])
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	if s.master != master {
+		logger.Info("redis sentinel %s master is %s", s.config.MasterName, master)
+	}
+	s.master = master
+	s.mu.Unlock()
+	return master, nil
+}
+
+// currentMaster 缓存的主节点地址，未知时为空
+func (s *redisSentinel) currentMaster() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.master
+}
+
+// invalidate 主节点失效，下次连接时重新询问哨兵
+func (s *redisSentinel) invalidate(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.master == addr {
+		s.master = ""
+		logger.Warn("redis sentinel %s master %s invalidated, re-resolving", s.config.MasterName, addr)
+	}
+}
+
+// replicaAddrs 可用的从节点地址
+func (s *redisSentinel) replicaAddrs(ctx context.Context) ([]string, error) {
+	var addrs []string
+	err := s.query(ctx, func(c redis.Conn) error {
+		values, err := redis.Values(redis.DoContext(c, ctx, "SENTINEL", "replicas", s.config.MasterName))
+		if err != nil {
+			return err
+		}
+		addrs = addrs[:0]
+		for _, value := range values {
+			replica, err := redis.StringMap(value, nil)
+			if err != nil {
+				return err
+			}
+			flags := replica["flags"]
+			if strings.Contains(flags, "s_down") || strings.Contains(flags, "o_down") || strings.Contains(flags, "disconnected") {
+				continue
+			}
+			addrs = append(addrs, net.JoinHostPort(replica["ip"], replica["port"]))
+		}
+		return nil
+	})
+	return addrs, err
+}
+
+// dialMaster 连接主节点并确认角色，失败时使缓存的地址失效
+func (s *redisSentinel) dialMaster(ctx context.Context) (redis.Conn, error) {
+	addr, err := s.masterAddr(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := dialRedis(ctx, s.config, addr)
+	if err != nil {
+		s.invalidate(addr)
+		return nil, err
+	}
+	role, err := redis.Values(redis.DoContext(c, ctx, "ROLE"))
+	if err == nil && len(role) > 0 {
+		if name, _ := redis.String(role[0], nil); name != "master" {
+			c.Close()
+			s.invalidate(addr)
+			return nil, fmt.Errorf("redis %s is %s, not master", addr, name)
+		}
+	} else if _, ok := err.(redis.Error); !ok && err != nil { // 不支持 ROLE 的旧版本跳过检查
+		c.Close()
+		s.invalidate(addr)
+		return nil, err
+	}
+	failed := false
+	return &reportConn{
+		Conn: c,
+		report: func(err error) {
+			if isRedisFailover(err) {
+				failed = true
+				s.invalidate(addr)
+			}
+		},
+		check: func() error {
+			if failed {
+				return errRedisStaleMaster
+			}
+			if master := s.currentMaster(); master != "" && master != addr {
+				return errRedisStaleMaster // 已切换主节点，丢弃旧连接
+			}
+			return nil
+		},
+	}, nil
+}
+
+// dialReplica 随机连接一个从节点，没有可用从节点时连接主节点
+func (s *redisSentinel) dialReplica(ctx context.Context) (redis.Conn, error) {
+	addrs, err := s.replicaAddrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	for _, addr := range addrs {
+		c, err := dialRedis(ctx, s.config, addr)
+		if err != nil {
+			continue
+		}
+		addr, failed := addr, false
+		return &reportConn{
+			Conn: c,
+			report: func(err error) {
+				if isRedisFailover(err) {
+					failed = true
+				}
+			},
+			check: func() error {
+				if failed || s.currentMaster() == addr {
+					return errRedisStaleMaster // 从节点已提升为主节点，重新选择从节点
+				}
+				return nil
+			},
+		}, nil
+	}
+	return s.dialMaster(ctx)
+}
+
+// checkOnBorrow 借出空闲连接前检查其是否仍指向正确的节点
+func checkOnBorrow(pool *redis.Pool) *redis.Pool {
+	testOnBorrow := pool.TestOnBorrow
+	pool.TestOnBorrow = func(c redis.Conn, t time.Time) error {
+		if err := c.Err(); err != nil {
+			return err
+		}
+		if testOnBorrow != nil {
+			return testOnBorrow(c, t)
+		}
+		return nil
+	}
+	return pool
+}
+
+// isRedisFailover 是否为主从切换导致的错误：网络错误或写入从节点
+func isRedisFailover(err error) bool {
+	if err == nil || err == redis.ErrNil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if replyErr, ok := err.(redis.Error); ok {
+		return strings.HasPrefix(string(replyErr), "READONLY")
+	}
+	return true
+}
+
+// removeString 移除切片中的元素
+func removeString(list []string, s string) []string {
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// 副本负载均衡策略
+const (
+	PolicyRandom     = "random"      // 随机
+	PolicyRoundRobin = "round_robin" // 轮询
+	PolicyLeastConn  = "least_conn"  // 最少连接
+)
+
+type forcePrimaryKey struct{}
+
+// WithPrimary 标记上下文强制走主库 (写后读场景)
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forcePrimaryKey{}, true)
+}
+
+// IsForcePrimary 判断上下文是否强制走主库
+func IsForcePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	force, _ := ctx.Value(forcePrimaryKey{}).(bool)
+	return force
+}
+
+// RoundRobinPolicy 轮询策略
+type RoundRobinPolicy struct {
+	next uint64
+}
+
+func (p *RoundRobinPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	n := atomic.AddUint64(&p.next, 1)
+	return connPools[(n-1)%uint64(len(connPools))]
+}
+
+// LeastConnPolicy 最少连接策略，按当前使用中的连接数选择
+type LeastConnPolicy struct{}
+
+func (LeastConnPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	best, bestInUse := -1, 0
+	for i, connPool := range connPools {
+		db, ok := connPool.(*sql.DB)
+		if !ok {
+			continue
+		}
+		if inUse := db.Stats().InUse; best == -1 || inUse < bestInUse {
+			best, bestInUse = i, inUse
+		}
+	}
+	if best == -1 {
+		return connPools[rand.Intn(len(connPools))]
+	}
+	return connPools[best]
+}
+
+// NewResolverPolicy 根据名称创建负载均衡策略
+func NewResolverPolicy(name string) dbresolver.Policy {
+	switch strings.ToLower(name) {
+	case PolicyRoundRobin:
+		return &RoundRobinPolicy{}
+	case PolicyLeastConn:
+		return LeastConnPolicy{}
+	default:
+		return dbresolver.RandomPolicy{}
+	}
+}
+
+// registerReplicas 为连接注册只读副本，读请求走副本，写请求与事务走主库
+func registerReplicas(db *gorm.DB, replicas []gorm.Dialector, policy string) (*dbresolver.DBResolver, error) {
+	primary := db.Config.ConnPool
+	// 上下文标记强制主库时追加写模式子句，并直接切换到主库，不依赖与 dbresolver 回调的先后顺序
+	forcePrimary := func(db *gorm.DB) {
+		if !IsForcePrimary(db.Statement.Context) {
+			return
+		}
+		if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok {
+			return // 事务内不切换
+		}
+		db.Statement.AddClause(dbresolver.Write)
+		db.Statement.ConnPool = primary
+	}
+	if err := db.Callback().Query().Before("*").Register("scaffold:force_primary", forcePrimary); err != nil {
+		return nil, err
+	}
+	if err := db.Callback().Row().Before("*").Register("scaffold:force_primary", forcePrimary); err != nil {
+		return nil, err
+	}
+	if err := db.Callback().Raw().Before("*").Register("scaffold:force_primary", forcePrimary); err != nil {
+		return nil, err
+	}
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   NewResolverPolicy(policy),
+	})
+	// Use 之后 Call 只遍历按表注册的副本，在 Use 之前登记的回调会在创建副本连接时执行
+	var pools []*sql.DB
+	_ = resolver.Call(func(pool gorm.ConnPool) error {
+		if replica, ok := pool.(*sql.DB); ok && replica != connPool(db) {
+			pools = append(pools, replica)
+		}
+		return nil
+	})
+	if err := db.Use(resolver); err != nil {
+		for _, replica := range pools {
+			_ = replica.Close()
+		}
+		return nil, err
+	}
+	replicaPoolsLock.Lock()
+	replicaPools[resolver] = pools
+	replicaPoolsLock.Unlock()
+	return resolver, nil
+}
+
+// 只读副本连接池，注册副本时记录
+var (
+	replicaPoolsLock sync.Mutex
+	replicaPools     = map[*dbresolver.DBResolver][]*sql.DB{}
+)
+
+// resolverReplicas 只读副本连接池，按配置顺序排列
+func resolverReplicas(resolver *dbresolver.DBResolver) []*sql.DB {
+	replicaPoolsLock.Lock()
+	defer replicaPoolsLock.Unlock()
+	return replicaPools[resolver]
+}
+
+// closeReplicas 关闭只读副本连接
+func closeReplicas(resolver *dbresolver.DBResolver) error {
+	replicaPoolsLock.Lock()
+	pools := replicaPools[resolver]
+	delete(replicaPools, resolver)
+	replicaPoolsLock.Unlock()
+	var first error
+	for _, replica := range pools {
+		if err := replica.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
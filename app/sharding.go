@@ -0,0 +1,351 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MetaverseTopDJ/Scaffold/util"
+
+	"gorm.io/gorm"
+)
+
+// 分片策略
+const (
+	ShardHash   = "hash"   // 按键哈希取模
+	ShardRange  = "range"  // 按整数键范围
+	ShardLookup = "lookup" // 按键查表
+)
+
+var (
+	ErrShardNotFound  = errors.New("shard not found")
+	ErrShardKeyAbsent = errors.New("shard key not found in context")
+)
+
+// ShardRangeConfig 范围分片区间 [from, to)，to 为 0 时表示无上界
+type ShardRangeConfig struct {
+	From  int64 `mapstructure:"from"`
+	To    int64 `mapstructure:"to"`
+	Shard int   `mapstructure:"shard"`
+}
+
+// ShardingConfig 逻辑表分片配置
+type ShardingConfig struct {
+	Driver      string             `mapstructure:"driver"`       // 连接池驱动 mysql / postgres，默认 postgres
+	Strategy    string             `mapstructure:"strategy"`     // 分片策略 hash / range / lookup，默认 hash
+	Pools       []string           `mapstructure:"pools"`        // 命名连接池，分片 i 使用 pools[i % len(pools)]
+	Shards      int                `mapstructure:"shards"`       // 分片数，默认等于连接池数
+	TableFormat string             `mapstructure:"table_format"` // 分表名格式 如 %s_%02d，为空时不分表
+	Ranges      []ShardRangeConfig `mapstructure:"ranges"`       // range 策略区间
+	Lookup      map[string]int     `mapstructure:"lookup"`       // lookup 策略键到分片的映射，键不区分大小写
+}
+
+type ShardingMapConfig struct {
+	List map[string]*ShardingConfig `mapstructure:"list"`
+}
+
+// ShardStrategy 根据分片键计算分片序号
+type ShardStrategy interface {
+	Shard(key interface{}, shards int) (int, error)
+}
+
+// ShardStrategyFunc 函数形式的分片策略
+type ShardStrategyFunc func(key interface{}, shards int) (int, error)
+
+// Shard 实现 ShardStrategy
+func (f ShardStrategyFunc) Shard(key interface{}, shards int) (int, error) {
+	return f(key, shards)
+}
+
+// HashStrategy 整数键直接取模，其他键按 fnv 哈希取模
+type HashStrategy struct{}
+
+// Shard 实现 ShardStrategy
+func (HashStrategy) Shard(key interface{}, shards int) (int, error) {
+	if n, ok := shardInt(key); ok {
+		return int(uint64(n) % uint64(shards)), nil
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fmt.Sprint(key)))
+	return int(h.Sum32() % uint32(shards)), nil
+}
+
+// RangeStrategy 按整数键所在区间分片
+type RangeStrategy struct {
+	Ranges []ShardRangeConfig
+}
+
+// Shard 实现 ShardStrategy
+func (s RangeStrategy) Shard(key interface{}, shards int) (int, error) {
+	n, ok := shardInt(key)
+	if !ok {
+		return 0, fmt.Errorf("range shard key must be integer, got %T", key)
+	}
+	for _, r := range s.Ranges {
+		if n >= r.From && (r.To == 0 || n < r.To) {
+			return r.Shard, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: key %d out of range", ErrShardNotFound, n)
+}
+
+// LookupStrategy 按映射表分片
+type LookupStrategy struct {
+	Table map[string]int
+}
+
+// Shard 实现 ShardStrategy
+func (s LookupStrategy) Shard(key interface{}, shards int) (int, error) {
+	if shard, ok := s.Table[strings.ToLower(fmt.Sprint(key))]; ok {
+		return shard, nil
+	}
+	return 0, fmt.Errorf("%w: key %v not in lookup table", ErrShardNotFound, key)
+}
+
+// shardInt 将整数类型或整数字符串转为 int64
+func shardInt(key interface{}) (int64, bool) {
+	switch v := key.(type) {
+	case int:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// Sharder 逻辑表分片路由
+type Sharder struct {
+	table       string
+	driver      *sqlDriver
+	pools       []string
+	shards      int
+	tableFormat string
+	strategy    ShardStrategy
+}
+
+var (
+	shardingLock sync.RWMutex
+	shardings    = map[string]*Sharder{}
+)
+
+// InitShardingConfig 加载分片配置
+func InitShardingConfig(path string) error {
+	ShardingConfigMap := &ShardingMapConfig{}
+	if err := util.ParseConfig(path, ShardingConfigMap); err != nil {
+		return err
+	}
+	for table, config := range ShardingConfigMap.List {
+		if _, err := RegisterSharding(table, config, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterSharding 注册逻辑表分片，strategy 不为空时替代配置中的策略
+func RegisterSharding(table string, config *ShardingConfig, strategy ShardStrategy) (*Sharder, error) {
+	s, err := NewSharder(table, config, strategy)
+	if err != nil {
+		return nil, err
+	}
+	shardingLock.Lock()
+	shardings[table] = s
+	shardingLock.Unlock()
+	return s, nil
+}
+
+// NewSharder 创建分片路由
+func NewSharder(table string, config *ShardingConfig, strategy ShardStrategy) (*Sharder, error) {
+	s := &Sharder{table: table, pools: config.Pools, shards: config.Shards, tableFormat: config.TableFormat, strategy: strategy}
+	switch strings.ToLower(config.Driver) {
+	case "", "postgres", "postgresql":
+		s.driver = postgresDriver
+	case "mysql":
+		s.driver = mysqlDriver
+	default:
+		return nil, fmt.Errorf("sharding %s: invalid driver %q", table, config.Driver)
+	}
+	if len(s.pools) == 0 {
+		return nil, fmt.Errorf("sharding %s: pools is empty", table)
+	}
+	if s.shards <= 0 {
+		s.shards = len(s.pools)
+	}
+	if s.tableFormat == "" && s.shards > len(s.pools) {
+		return nil, fmt.Errorf("sharding %s: %d shards over %d pools requires table_format", table, s.shards, len(s.pools))
+	}
+	if s.strategy == nil {
+		switch strings.ToLower(config.Strategy) {
+		case "", ShardHash:
+			s.strategy = HashStrategy{}
+		case ShardRange:
+			s.strategy = RangeStrategy{Ranges: config.Ranges}
+		case ShardLookup:
+			lookup := make(map[string]int, len(config.Lookup))
+			for key, shard := range config.Lookup {
+				lookup[strings.ToLower(key)] = shard
+			}
+			s.strategy = LookupStrategy{Table: lookup}
+		default:
+			return nil, fmt.Errorf("sharding %s: invalid strategy %q", table, config.Strategy)
+		}
+	}
+	return s, nil
+}
+
+// GetSharder 获取逻辑表分片路由
+func GetSharder(table string) (*Sharder, error) {
+	shardingLock.RLock()
+	defer shardingLock.RUnlock()
+	if s, ok := shardings[table]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("sharding %s not registered", table)
+}
+
+// Shards 分片数
+func (s *Sharder) Shards() int {
+	return s.shards
+}
+
+// Shard 计算分片键所在分片
+func (s *Sharder) Shard(key interface{}) (int, error) {
+	shard, err := s.strategy.Shard(key, s.shards)
+	if err != nil {
+		return 0, err
+	}
+	if shard < 0 || shard >= s.shards {
+		return 0, fmt.Errorf("%w: shard %d of %s out of bounds", ErrShardNotFound, shard, s.table)
+	}
+	return shard, nil
+}
+
+// TableName 分片的物理表名
+func (s *Sharder) TableName(shard int) string {
+	if s.tableFormat == "" {
+		return s.table
+	}
+	return fmt.Sprintf(s.tableFormat, s.table, shard)
+}
+
+// ShardDB 获取分片的连接，已指定物理表
+func (s *Sharder) ShardDB(ctx context.Context, shard int) (*gorm.DB, error) {
+	if shard < 0 || shard >= s.shards {
+		return nil, fmt.Errorf("%w: shard %d of %s out of bounds", ErrShardNotFound, shard, s.table)
+	}
+	db, err := getSQLPool(s.driver, s.pools[shard%len(s.pools)])
+	if err != nil {
+		return nil, err
+	}
+	return db.WithContext(ctx).Table(s.TableName(shard)).Session(&gorm.Session{}), nil
+}
+
+// DB 根据分片键获取连接
+func (s *Sharder) DB(ctx context.Context, key interface{}) (*gorm.DB, error) {
+	shard, err := s.Shard(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.ShardDB(ctx, shard)
+}
+
+// Each 并发在所有分片上执行 fn，返回所有分片的错误
+func (s *Sharder) Each(ctx context.Context, fn func(shard int, db *gorm.DB) error) error {
+	errs := make([]error, s.shards)
+	var wg sync.WaitGroup
+	for shard := 0; shard < s.shards; shard++ {
+		db, err := s.ShardDB(ctx, shard)
+		if err != nil {
+			errs[shard] = err
+			continue
+		}
+		wg.Add(1)
+		go func(shard int, db *gorm.DB) {
+			defer wg.Done()
+			errs[shard] = fn(shard, db)
+		}(shard, db)
+	}
+	wg.Wait()
+	var messages []string
+	for shard, err := range errs {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("shard %d: %v", shard, err))
+		}
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("sharding %s: %s", s.table, strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// ScatterGather 在所有分片上并发查询并按分片顺序合并结果
+func ScatterGather[T any](ctx context.Context, s *Sharder, fn func(db *gorm.DB) ([]T, error)) ([]T, error) {
+	results := make([][]T, s.shards)
+	err := s.Each(ctx, func(shard int, db *gorm.DB) error {
+		list, err := fn(db)
+		results[shard] = list
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var list []T
+	for _, r := range results {
+		list = append(list, r...)
+	}
+	return list, nil
+}
+
+type shardKeyContextKey struct{ table string }
+
+// WithShardKey 在上下文中设置逻辑表的分片键，table 为空时对所有逻辑表生效
+func WithShardKey(ctx context.Context, table string, key interface{}) context.Context {
+	return context.WithValue(ctx, shardKeyContextKey{table}, key)
+}
+
+// ShardKeyFromContext 获取上下文中的分片键
+func ShardKeyFromContext(ctx context.Context, table string) (interface{}, bool) {
+	if key := ctx.Value(shardKeyContextKey{table}); key != nil {
+		return key, true
+	}
+	if key := ctx.Value(shardKeyContextKey{}); key != nil {
+		return key, true
+	}
+	return nil, false
+}
+
+// GetShardDB 根据上下文中的分片键获取逻辑表连接
+func GetShardDB(ctx context.Context, table string) (*gorm.DB, error) {
+	s, err := GetSharder(table)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := ShardKeyFromContext(ctx, table)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrShardKeyAbsent, table)
+	}
+	return s.DB(ctx, key)
+}
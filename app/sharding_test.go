@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type shardItem struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+}
+
+func TestShardDBIsNewSession(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "shard.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := PostgresPool
+	PostgresPool = map[string]*gorm.DB{"shard": db}
+	t.Cleanup(func() { PostgresPool = saved })
+
+	s, err := NewSharder("items", &ShardingConfig{Pools: []string{"shard"}, Shards: 2, TableFormat: "%s_%d"}, HashStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for shard := 0; shard < 2; shard++ {
+		if err := db.Table(s.TableName(shard)).AutoMigrate(&shardItem{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx, err := s.ShardDB(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Create(&[]shardItem{{Name: "a"}, {Name: "b"}})
+
+	// 重复使用同一个连接时条件不应累积
+	var a, b []shardItem
+	if err := tx.Where("name = ?", "a").Find(&a).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Where("name = ?", "b").Find(&b).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 1 || len(b) != 1 {
+		t.Fatalf("got %d and %d rows, want 1 and 1", len(a), len(b))
+	}
+}
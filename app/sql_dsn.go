@@ -0,0 +1,141 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+// mysqlParamNames MySQL 驱动参数名，viper 会将配置键转为小写，需要还原大小写
+var mysqlParamNames = map[string]string{}
+
+func init() {
+	for _, name := range []string{
+		"allowAllFiles", "allowCleartextPasswords", "allowNativePasswords", "allowOldPasswords",
+		"charset", "checkConnLiveness", "clientFoundRows", "collation", "columnsWithAlias",
+		"interpolateParams", "loc", "maxAllowedPacket", "multiStatements", "parseTime",
+		"readTimeout", "rejectReadOnly", "serverPubKey", "timeout", "tls", "writeTimeout",
+	} {
+		mysqlParamNames[strings.ToLower(name)] = name
+	}
+}
+
+// mysqlDSN 根据独立字段生成 MySQL 数据源，data_source_name 不为空时优先使用
+func mysqlDSN(c *SQLConfig) (string, error) {
+	if c.DataSourceName != "" {
+		return c.DataSourceName, nil
+	}
+	if c.Host == "" {
+		return "", errors.New("mysql config requires data_source_name or host")
+	}
+	port := c.Port
+	if port == 0 {
+		port = 3306
+	}
+	charset := c.Charset
+	if charset == "" {
+		charset = "utf8mb4"
+	}
+	query := url.Values{}
+	query.Set("charset", charset)
+	query.Set("parseTime", "true")
+	if c.TimeZone != "" {
+		query.Set("loc", c.TimeZone)
+	}
+	for key, value := range c.Params {
+		if name, ok := mysqlParamNames[strings.ToLower(key)]; ok {
+			key = name
+		}
+		query.Set(key, value)
+	}
+	password, err := c.password()
+	if err != nil {
+		return "", err
+	}
+	dsn := c.User
+	if password != "" {
+		dsn += ":" + password
+	}
+	dsn += "@tcp(" + net.JoinHostPort(c.Host, strconv.Itoa(port)) + ")/" + c.Database + "?" + query.Encode()
+	if _, err := gomysql.ParseDSN(dsn); err != nil {
+		return "", err
+	}
+	return dsn, nil
+}
+
+// postgresDSN 根据独立字段生成 Postgres 数据源，data_source_name 不为空时优先使用
+func postgresDSN(c *SQLConfig) (string, error) {
+	if c.DataSourceName != "" {
+		return c.DataSourceName, nil
+	}
+	if c.Host == "" {
+		return "", errors.New("postgres config requires data_source_name or host")
+	}
+	port := c.Port
+	if port == 0 {
+		port = 5432
+	}
+	sslMode := c.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	params := map[string]string{
+		"host":    c.Host,
+		"port":    strconv.Itoa(port),
+		"user":    c.User,
+		"dbname":  c.Database,
+		"sslmode": sslMode,
+	}
+	password, err := c.password()
+	if err != nil {
+		return "", err
+	}
+	if password != "" {
+		params["password"] = password
+	}
+	if c.TimeZone != "" {
+		params["TimeZone"] = c.TimeZone
+	}
+	for key, value := range c.Params {
+		params[key] = value
+	}
+	keys := make([]string, 0, len(params))
+	for key, value := range params {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+quotePostgresValue(params[key]))
+	}
+	return strings.Join(pairs, " "), nil
+}
+
+// password 密码，password_file 不为空时读取文件内容
+func (c *SQLConfig) password() (string, error) {
+	if c.PasswordFile == "" {
+		return c.Password, nil
+	}
+	data, err := ioutil.ReadFile(c.PasswordFile)
+	if err != nil {
+		return "", fmt.Errorf("read password file %s failed: %v", c.PasswordFile, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// quotePostgresValue 按 libpq 规则为参数值加引号
+func quotePostgresValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
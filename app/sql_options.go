@@ -0,0 +1,204 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// TLS 校验模式
+const (
+	TLSModeDisable    = "disable"     // 不使用 TLS
+	TLSModeSkipVerify = "skip-verify" // 使用 TLS 但不校验证书
+	TLSModeVerifyCA   = "verify-ca"   // 校验证书链，不校验主机名
+	TLSModeVerifyFull = "verify-full" // 校验证书链与主机名
+)
+
+// SQLTLSConfig SQL 连接 TLS 配置
+type SQLTLSConfig struct {
+	Mode       string `mapstructure:"mode"`        // 校验模式 disable / skip-verify / verify-ca / verify-full，为空时沿用数据源配置
+	CA         string `mapstructure:"ca"`          // CA 证书文件
+	Cert       string `mapstructure:"cert"`        // 客户端证书文件
+	Key        string `mapstructure:"key"`         // 客户端私钥文件
+	ServerName string `mapstructure:"server_name"` // 服务器名称，为空时使用连接地址
+}
+
+// buildTLSConfig 根据配置创建 tls.Config，模式为空或 disable 时返回 nil
+func buildTLSConfig(c SQLTLSConfig, host string) (*tls.Config, error) {
+	mode := strings.ToLower(c.Mode)
+	if mode == "" || mode == TLSModeDisable {
+		return nil, nil
+	}
+	config := &tls.Config{ServerName: c.ServerName}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	if c.CA != "" {
+		pem, err := ioutil.ReadFile(c.CA)
+		if err != nil {
+			return nil, fmt.Errorf("read tls ca %s failed: %v", c.CA, err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid tls ca %s", c.CA)
+		}
+	}
+	if c.Cert != "" || c.Key != "" {
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("load tls client cert failed: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	switch mode {
+	case TLSModeSkipVerify:
+		config.InsecureSkipVerify = true
+	case TLSModeVerifyCA:
+		// 跳过默认校验 (含主机名)，仅校验证书链
+		config.InsecureSkipVerify = true
+		roots := config.RootCAs
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("tls: no server certificate")
+			}
+			certs := make([]*x509.Certificate, len(rawCerts))
+			for i, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs[i] = cert
+			}
+			options := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+			for _, cert := range certs[1:] {
+				options.Intermediates.AddCert(cert)
+			}
+			_, err := certs[0].Verify(options)
+			return err
+		}
+	case TLSModeVerifyFull:
+	default:
+		return nil, fmt.Errorf("invalid tls mode %q", c.Mode)
+	}
+	return config, nil
+}
+
+// gormConfig 根据连接池配置创建 gorm 配置
+func (c *SQLConfig) gormConfig() *gorm.Config {
+	return &gorm.Config{
+		QueryFields:            true,
+		PrepareStmt:            c.PrepareStmt,
+		SkipDefaultTransaction: c.SkipDefaultTransaction,
+		DryRun:                 c.DryRun,
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   c.TablePrefix,
+			SingularTable: c.SingularTable,
+		},
+	}
+}
+
+// connMaxLifetime 连接最长复用时间，conn_max_lifetime 优先于 max_conn_life_time (秒)
+func (c *SQLConfig) connMaxLifetime() (time.Duration, error) {
+	return parseDuration(c.ConnMaxLifetime, time.Duration(c.MaxConnLifeTime)*time.Second)
+}
+
+// mysqlDialector 创建 MySQL Dialector，应用 TLS 与超时设置
+func mysqlDialector(name, dsn string, c *SQLConfig) (gorm.Dialector, error) {
+	statementTimeout, err := parseDuration(c.StatementTimeout, 0)
+	if err != nil {
+		return nil, err
+	}
+	lockTimeout, err := parseDuration(c.LockTimeout, 0)
+	if err != nil {
+		return nil, err
+	}
+	if c.TLS.Mode == "" && statementTimeout == 0 && lockTimeout == 0 {
+		return mysql.New(mysql.Config{DSN: dsn}), nil
+	}
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	if statementTimeout > 0 {
+		cfg.Params["max_execution_time"] = strconv.FormatInt(statementTimeout.Milliseconds(), 10)
+	}
+	if lockTimeout > 0 {
+		seconds := int64(lockTimeout / time.Second)
+		if seconds < 1 {
+			seconds = 1 // innodb_lock_wait_timeout 最小为 1 秒
+		}
+		cfg.Params["innodb_lock_wait_timeout"] = strconv.FormatInt(seconds, 10)
+	}
+	if c.TLS.Mode != "" {
+		host := cfg.Addr
+		if i := strings.LastIndex(host, ":"); i > 0 {
+			host = host[:i]
+		}
+		tlsConfig, err := buildTLSConfig(c.TLS, host)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = "false"
+		if tlsConfig != nil {
+			key := "scaffold_" + name
+			if err := gomysql.RegisterTLSConfig(key, tlsConfig); err != nil {
+				return nil, err
+			}
+			cfg.TLSConfig = key
+		}
+	}
+	return mysql.New(mysql.Config{DSN: cfg.FormatDSN()}), nil
+}
+
+// postgresDialector 创建 Postgres Dialector，应用 TLS 与超时设置
+func postgresDialector(name, dsn string, c *SQLConfig) (gorm.Dialector, error) {
+	statementTimeout, err := parseDuration(c.StatementTimeout, 0)
+	if err != nil {
+		return nil, err
+	}
+	lockTimeout, err := parseDuration(c.LockTimeout, 0)
+	if err != nil {
+		return nil, err
+	}
+	if c.TLS.Mode == "" && statementTimeout == 0 && lockTimeout == 0 {
+		return postgres.New(postgres.Config{DSN: dsn}), nil
+	}
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RuntimeParams == nil {
+		cfg.RuntimeParams = map[string]string{}
+	}
+	if statementTimeout > 0 {
+		cfg.RuntimeParams["statement_timeout"] = strconv.FormatInt(statementTimeout.Milliseconds(), 10)
+	}
+	if lockTimeout > 0 {
+		cfg.RuntimeParams["lock_timeout"] = strconv.FormatInt(lockTimeout.Milliseconds(), 10)
+	}
+	if c.TLS.Mode != "" {
+		tlsConfig, err := buildTLSConfig(c.TLS, cfg.Host)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = tlsConfig
+		cfg.Fallbacks = nil // 显式配置 TLS 时不回退
+	}
+	return postgres.New(postgres.Config{Conn: stdlib.OpenDB(*cfg)}), nil
+}
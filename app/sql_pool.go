@@ -0,0 +1,465 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// 连接重试默认参数
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = time.Second * 30
+)
+
+// SQLConfig SQL 连接池通用配置
+type SQLConfig struct {
+	DriverName      string   `mapstructure:"driver_name"`        // 数据库驱动
+	DataSourceName  string   `mapstructure:"data_source_name"`   // 数据源，不为空时优先于独立字段
+	MaxOpenConn     int      `mapstructure:"max_open_conn"`      // 最大连接数
+	MaxIdleConn     int      `mapstructure:"max_idle_conn"`      // 空闲连接池的最大连接数
+	MaxConnLifeTime int      `mapstructure:"max_conn_life_time"` // 可以重用的最长连接时间 (秒)
+	ConnMaxLifetime string   `mapstructure:"conn_max_lifetime"`  // 可以重用的最长连接时间 如 1h，优先于 max_conn_life_time
+	ConnMaxIdleTime string   `mapstructure:"conn_max_idle_time"` // 连接最长空闲时间 如 10m
+	Replicas        []string `mapstructure:"replicas"`           // 只读副本数据源
+	Policy          string   `mapstructure:"policy"`             // 副本负载均衡策略 random / round_robin / least_conn
+	LogLevel        string   `mapstructure:"log_level"`          // SQL 日志等级，为空时使用全局等级
+	SlowThreshold   string   `mapstructure:"slow_threshold"`     // 慢 SQL 阀值 如 500ms，默认 2s
+	Colorful        bool     `mapstructure:"colorful"`           // 彩色打印 SQL
+	ConnectRetries  int      `mapstructure:"connect_retries"`    // 连接失败重试次数，延迟连接时 0 表示一直重试
+	RetryBackoff    string   `mapstructure:"retry_backoff"`      // 初始重试间隔 如 1s，默认 1s
+	RetryMaxBackoff string   `mapstructure:"retry_max_backoff"`  // 最大重试间隔 如 30s，默认 30s
+	Lazy            bool     `mapstructure:"lazy"`               // 延迟连接，服务启动后在后台连接
+	DrainTimeout    string   `mapstructure:"drain_timeout"`      // 重建连接池后等待旧连接池查询结束的最长时间 如 30s，默认 30s
+
+	PrepareStmt            bool         `mapstructure:"prepare_stmt"`             // 缓存预编译语句
+	StatementTimeout       string       `mapstructure:"statement_timeout"`        // 默认语句超时 如 30s
+	LockTimeout            string       `mapstructure:"lock_timeout"`             // 默认锁等待超时 如 5s
+	SkipDefaultTransaction bool         `mapstructure:"skip_default_transaction"` // 写操作跳过默认事务
+	TablePrefix            string       `mapstructure:"table_prefix"`             // 表名前缀
+	SingularTable          bool         `mapstructure:"singular_table"`           // 使用单数表名
+	DryRun                 bool         `mapstructure:"dry_run"`                  // 只生成 SQL 不执行
+	TLS                    SQLTLSConfig `mapstructure:"tls"`                      // TLS 配置
+
+	Host         string            `mapstructure:"host"`          // 主机
+	Port         int               `mapstructure:"port"`          // 端口，默认 3306 / 5432
+	User         string            `mapstructure:"user"`          // 用户名
+	Password     string            `mapstructure:"password"`      // 密码
+	PasswordFile string            `mapstructure:"password_file"` // 密码文件，优先于 password，文件变化时可重建连接池 (见 WatchSQLConfig)
+	Database     string            `mapstructure:"database"`      // 数据库名
+	Charset      string            `mapstructure:"charset"`       // MySQL 字符集，默认 utf8mb4
+	SSLMode      string            `mapstructure:"sslmode"`       // Postgres sslmode，默认 disable
+	TimeZone     string            `mapstructure:"timezone"`      // 时区 如 Asia/Shanghai
+	Params       map[string]string `mapstructure:"params"`        // 其他数据源参数
+}
+
+// sqlDriver SQL 数据库驱动及其命名连接池
+type sqlDriver struct {
+	name       string
+	parse      func(path string) (map[string]*SQLConfig, error)
+	dsn        func(config *SQLConfig) (string, error)
+	dialector  func(name, dsn string, config *SQLConfig) (gorm.Dialector, error)
+	successTag string
+	failedTag  string
+	pools      *map[string]*gorm.DB
+	resolvers  *map[string]*dbresolver.DBResolver
+	cancel     context.CancelFunc
+	configPath string                   // 配置文件，用于重新加载
+	configs    map[string]*SQLConfig    // 连接池当前使用的配置
+	connectors map[string]*sqlConnector // 连接池的后台连接，重建或移除时取消
+}
+
+// sqlConnector 连接池的一次连接过程，被重建或移除取代后不再登记连接池与更新状态
+type sqlConnector struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	superseded bool // 受 sqlPoolLock 保护
+}
+
+// supersede 取消 name 仍在重试的连接，调用方需持有 sqlPoolLock 写锁
+func (driver *sqlDriver) supersede(name string) {
+	if c, ok := driver.connectors[name]; ok {
+		c.superseded = true
+		c.cancel()
+		delete(driver.connectors, name)
+	}
+}
+
+// setStatus 未被取代时更新连接池状态
+func (c *sqlConnector) setStatus(driver *sqlDriver, name, state string, attempts int, err error) {
+	sqlPoolLock.RLock()
+	defer sqlPoolLock.RUnlock()
+	if !c.superseded {
+		setPoolStatus(driver.name, name, state, attempts, err)
+	}
+}
+
+// SQLPluginFunc 为命名连接池创建 gorm 插件
+type SQLPluginFunc func(driver, name string) gorm.Plugin
+
+var (
+	sqlPoolLock sync.RWMutex    // 保护 MySQLPool / PostgresPool 及其副本
+	sqlPlugins  []SQLPluginFunc // 所有连接池使用的插件
+
+	mysqlDriver = &sqlDriver{
+		name:       "mysql",
+		parse:      parseMySQLConfig,
+		dsn:        mysqlDSN,
+		dialector:  mysqlDialector,
+		successTag: logger.DLTagMySQLSuccess,
+		failedTag:  logger.DLTagMySQLFailed,
+		pools:      &MySQLPool,
+		resolvers:  &mysqlResolvers,
+	}
+	postgresDriver = &sqlDriver{
+		name:       "postgres",
+		parse:      parsePostgresConfig,
+		dsn:        postgresDSN,
+		dialector:  postgresDialector,
+		successTag: logger.DLTagPgSQLSuccess,
+		failedTag:  logger.DLTagPgSQLFailed,
+		pools:      &PostgresPool,
+		resolvers:  &postgresResolvers,
+	}
+)
+
+// initSQLPools 初始化驱动下的所有命名连接池，单个连接池失败不影响其他连接池
+func initSQLPools(driver *sqlDriver, path string, list map[string]*SQLConfig) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	sqlPoolLock.Lock()
+	if driver.cancel != nil {
+		driver.cancel()
+	}
+	driver.cancel = cancel
+	driver.configPath = path
+	driver.configs = list
+	driver.connectors = make(map[string]*sqlConnector, len(list))
+	for name := range list {
+		c := &sqlConnector{}
+		c.ctx, c.cancel = context.WithCancel(ctx)
+		driver.connectors[name] = c
+	}
+	connectors := driver.connectors
+	*driver.pools = map[string]*gorm.DB{}
+	*driver.resolvers = map[string]*dbresolver.DBResolver{}
+	sqlPoolLock.Unlock()
+
+	names := make([]string, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []string
+	for _, name := range names {
+		config, c := list[name], connectors[name]
+		c.setStatus(driver, name, PoolConnecting, 0, nil)
+		if config.Lazy {
+			go func(name string, config *SQLConfig) {
+				_ = connectSQLPool(c, driver, name, config)
+			}(name, config)
+			continue
+		}
+		if err := connectSQLPool(c, driver, name, config); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// connectSQLPool 按指数退避重试连接，成功后登记连接池；连接池已关闭或被重建、移除时放弃
+func connectSQLPool(c *sqlConnector, driver *sqlDriver, name string, config *SQLConfig) error {
+	defer c.cancel()
+	backoff, err := parseDuration(config.RetryBackoff, defaultRetryBackoff)
+	if err != nil {
+		c.setStatus(driver, name, PoolFailed, 0, err)
+		return err
+	}
+	maxBackoff, err := parseDuration(config.RetryMaxBackoff, defaultRetryMaxBackoff)
+	if err != nil {
+		c.setStatus(driver, name, PoolFailed, 0, err)
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		db, resolver, err := openSQLPool(driver, name, config)
+		if err == nil {
+			sqlPoolLock.Lock()
+			if c.ctx.Err() != nil { // 连接池已关闭或被取代
+				sqlPoolLock.Unlock()
+				closeOpenedPool(db, resolver)
+				return c.ctx.Err()
+			}
+			if err := useSQLPlugins(driver.name, name, db); err != nil {
+				sqlPoolLock.Unlock()
+				closeOpenedPool(db, resolver)
+				c.setStatus(driver, name, PoolFailed, attempt, err)
+				return err
+			}
+			(*driver.pools)[name] = db
+			if resolver != nil {
+				(*driver.resolvers)[name] = resolver
+			}
+			setPoolStatus(driver.name, name, PoolReady, attempt, nil) // 持有写锁，不会被取代
+			sqlPoolLock.Unlock()
+			logger.Info("%s pool %s connected after %d attempt(s)", driver.name, name, attempt)
+			return nil
+		}
+		c.setStatus(driver, name, PoolConnecting, attempt, err)
+		unlimited := config.Lazy && config.ConnectRetries == 0
+		if !unlimited && attempt > config.ConnectRetries {
+			c.setStatus(driver, name, PoolFailed, attempt, err)
+			logger.Error("%s pool %s connect failed after %d attempt(s): %v", driver.name, name, attempt, err)
+			return err
+		}
+		logger.Warn("%s pool %s connect failed, retry in %v: %v", driver.name, name, backoff, err)
+		select {
+		case <-c.ctx.Done():
+			c.setStatus(driver, name, PoolClosed, attempt, c.ctx.Err())
+			return c.ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// openSQLPool 打开单个连接池，并注册只读副本
+func openSQLPool(driver *sqlDriver, name string, config *SQLConfig) (*gorm.DB, *dbresolver.DBResolver, error) {
+	slowThreshold, err := ParseSlowThreshold(config.SlowThreshold)
+	if err != nil {
+		return nil, nil, err
+	}
+	lifetime, err := config.connMaxLifetime()
+	if err != nil {
+		return nil, nil, err
+	}
+	idleTime, err := parseDuration(config.ConnMaxIdleTime, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	dsn, err := driver.dsn(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	dialector, err := driver.dialector(name, dsn, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	gormConfig := config.gormConfig()
+	gormConfig.Logger = NewGormLogger(logger.Log, GormLoggerConfig{
+		Pool:          name,
+		SuccessTag:    driver.successTag,
+		FailedTag:     driver.failedTag,
+		SlowThreshold: slowThreshold,                                // 慢 SQL 阀值
+		LogLevel:      ParseGormLogLevel(config.LogLevel, logLevel), // Log Level
+		Colorful:      config.Colorful,                              // 彩色打印
+	})
+	DBGorm, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		closeFailedOpen(DBGorm, dialector)
+		return nil, nil, err
+	}
+	PQ, err := DBGorm.DB()
+	if err != nil {
+		closeFailedOpen(DBGorm, dialector)
+		return nil, nil, err
+	}
+	PQ.SetMaxOpenConns(config.MaxOpenConn)
+	PQ.SetMaxIdleConns(config.MaxIdleConn)
+	PQ.SetConnMaxLifetime(lifetime)
+	PQ.SetConnMaxIdleTime(idleTime)
+	if len(config.Replicas) == 0 {
+		return DBGorm, nil, nil
+	}
+	// 注册只读副本
+	replicas := make([]gorm.Dialector, 0, len(config.Replicas))
+	for i, dsn := range config.Replicas {
+		replica, err := driver.dialector(fmt.Sprintf("%s_replica_%d", name, i), dsn, config)
+		if err != nil {
+			closeDialectors(replicas)
+			_ = PQ.Close()
+			return nil, nil, err
+		}
+		replicas = append(replicas, replica)
+	}
+	resolver, err := registerReplicas(DBGorm, replicas, config.Policy)
+	if err != nil {
+		closeDialectors(replicas)
+		_ = PQ.Close()
+		return nil, nil, err
+	}
+	for _, replica := range resolverReplicas(resolver) {
+		replica.SetMaxOpenConns(config.MaxOpenConn)
+		replica.SetMaxIdleConns(config.MaxIdleConn)
+		replica.SetConnMaxLifetime(lifetime)
+		replica.SetConnMaxIdleTime(idleTime)
+	}
+	return DBGorm, resolver, nil
+}
+
+// RegisterSQLPlugin 注册插件，已连接和之后连接的 MySQL / Postgres 连接池都会使用
+func RegisterSQLPlugin(fn SQLPluginFunc) error {
+	sqlPoolLock.Lock()
+	defer sqlPoolLock.Unlock()
+	sqlPlugins = append(sqlPlugins, fn)
+	for _, driver := range []*sqlDriver{mysqlDriver, postgresDriver} {
+		for name, db := range *driver.pools {
+			if err := db.Use(fn(driver.name, name)); err != nil && !errors.Is(err, gorm.ErrRegistered) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// useSQLPlugins 为新连接池注册插件，调用方需持有 sqlPoolLock
+func useSQLPlugins(driver, name string, db *gorm.DB) error {
+	for _, fn := range sqlPlugins {
+		if err := db.Use(fn(driver, name)); err != nil && !errors.Is(err, gorm.ErrRegistered) {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSQLPool 登记外部创建的连接池，db 为 nil 时移除该连接池
+func setSQLPool(driver *sqlDriver, name string, db *gorm.DB) error {
+	sqlPoolLock.Lock()
+	if db == nil {
+		delete(*driver.pools, name)
+		sqlPoolLock.Unlock()
+		setPoolStatus(driver.name, name, PoolClosed, 0, nil)
+		return nil
+	}
+	if err := useSQLPlugins(driver.name, name, db); err != nil {
+		sqlPoolLock.Unlock()
+		return err
+	}
+	if *driver.pools == nil {
+		*driver.pools = map[string]*gorm.DB{}
+	}
+	(*driver.pools)[name] = db
+	sqlPoolLock.Unlock()
+	setPoolStatus(driver.name, name, PoolReady, 1, nil)
+	return nil
+}
+
+// closeOpenedPool 关闭未登记的连接池
+func closeOpenedPool(db *gorm.DB, resolver *dbresolver.DBResolver) {
+	if resolver != nil {
+		_ = closeReplicas(resolver)
+	}
+	if PQ, err := db.DB(); err == nil {
+		_ = PQ.Close()
+	}
+}
+
+// closeFailedOpen 关闭打开失败时 gorm 已创建的连接池，gorm.Open 在 Ping 失败时不会关闭
+func closeFailedOpen(db *gorm.DB, dialector gorm.Dialector) {
+	if db != nil {
+		if PQ, err := db.DB(); err == nil {
+			_ = PQ.Close()
+			return
+		}
+	}
+	closeDialectors([]gorm.Dialector{dialector})
+}
+
+// closeDialectors 关闭方言中预先创建的连接，如 postgresDialector 通过 stdlib.OpenDB 创建的 *sql.DB
+func closeDialectors(dialectors []gorm.Dialector) {
+	for _, dialector := range dialectors {
+		if d, ok := dialector.(*postgres.Dialector); ok && d.Conn != nil {
+			if closer, ok := d.Conn.(interface{ Close() error }); ok {
+				_ = closer.Close()
+			}
+		}
+	}
+}
+
+// getSQLPool 获取命名连接池
+func getSQLPool(driver *sqlDriver, name string) (*gorm.DB, error) {
+	sqlPoolLock.RLock()
+	db, ok := (*driver.pools)[name]
+	sqlPoolLock.RUnlock()
+	if ok {
+		return db, nil
+	}
+	if status, ok := GetPoolStatus(driver.name, name); ok && status.State != PoolReady {
+		return nil, fmt.Errorf("%s pool %s is %s", driver.name, name, status.State)
+	}
+	return nil, errors.New("get pool error")
+}
+
+// sqlPools 命名连接池快照
+func sqlPools(driver *sqlDriver) map[string]*gorm.DB {
+	sqlPoolLock.RLock()
+	defer sqlPoolLock.RUnlock()
+	pools := make(map[string]*gorm.DB, len(*driver.pools))
+	for name, db := range *driver.pools {
+		pools[name] = db
+	}
+	return pools
+}
+
+// sqlReplicas 命名连接池只读副本的快照，按配置顺序排列
+func sqlReplicas(driver *sqlDriver) map[string][]*sql.DB {
+	sqlPoolLock.RLock()
+	defer sqlPoolLock.RUnlock()
+	replicas := map[string][]*sql.DB{}
+	for name, resolver := range *driver.resolvers {
+		if pools := resolverReplicas(resolver); len(pools) > 0 {
+			replicas[name] = pools
+		}
+	}
+	return replicas
+}
+
+// closeSQLPools 停止后台连接并关闭所有连接池
+func closeSQLPools(driver *sqlDriver) error {
+	sqlPoolLock.Lock()
+	defer sqlPoolLock.Unlock()
+	if driver.cancel != nil {
+		driver.cancel()
+		driver.cancel = nil
+	}
+	for name, pool := range *driver.pools {
+		if resolver, ok := (*driver.resolvers)[name]; ok {
+			if err := closeReplicas(resolver); err != nil {
+				return err
+			}
+		}
+		db, err := pool.DB()
+		if err != nil {
+			return err
+		}
+		err = db.Close()
+		if err != nil {
+			return err
+		}
+		setPoolStatus(driver.name, name, PoolClosed, 0, nil)
+	}
+	return nil
+}
+
+// parseDuration 解析 "500ms" "30s" 等格式的时长，空值返回默认值
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+func TestReloadSupersedesLazyConnector(t *testing.T) {
+	dir := t.TempDir()
+	oldDSN, newDSN := filepath.Join(dir, "old.db"), filepath.Join(dir, "new.db")
+	var oldDown int32 = 1 // 旧数据源不可用，延迟连接持续重试
+	var pools map[string]*gorm.DB
+	var resolvers map[string]*dbresolver.DBResolver
+	driver := &sqlDriver{
+		name: "test",
+		dsn:  func(config *SQLConfig) (string, error) { return config.DataSourceName, nil },
+		dialector: func(name, dsn string, config *SQLConfig) (gorm.Dialector, error) {
+			if dsn == oldDSN && atomic.LoadInt32(&oldDown) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return sqlite.Open(dsn), nil
+		},
+		pools:     &pools,
+		resolvers: &resolvers,
+	}
+	t.Cleanup(func() { driver.cancel() })
+	if err := initSQLPools(driver, "", map[string]*SQLConfig{
+		"main": {DataSourceName: oldDSN, Lazy: true, RetryBackoff: "10ms", RetryMaxBackoff: "10ms"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if err := reloadSQLPool(driver, "main", &SQLConfig{DataSourceName: newDSN}); err != nil {
+		t.Fatal(err)
+	}
+	sqlPoolLock.RLock()
+	reloaded := pools["main"]
+	sqlPoolLock.RUnlock()
+	atomic.StoreInt32(&oldDown, 0)
+	time.Sleep(50 * time.Millisecond)
+
+	sqlPoolLock.RLock()
+	current := pools["main"]
+	sqlPoolLock.RUnlock()
+	if PQ, err := current.DB(); err == nil {
+		defer PQ.Close()
+	}
+	if current != reloaded {
+		t.Fatal("stale lazy connector replaced the reloaded pool")
+	}
+	if status, _ := GetPoolStatus("test", "main"); status.State != PoolReady {
+		t.Fatalf("status = %s, want %s", status.State, PoolReady)
+	}
+}
+
+func TestSQLReplicas(t *testing.T) {
+	dir := t.TempDir()
+	var pools map[string]*gorm.DB
+	var resolvers map[string]*dbresolver.DBResolver
+	driver := &sqlDriver{
+		name: "test",
+		dsn:  func(config *SQLConfig) (string, error) { return config.DataSourceName, nil },
+		dialector: func(name, dsn string, config *SQLConfig) (gorm.Dialector, error) {
+			return sqlite.Open(dsn), nil
+		},
+		pools:     &pools,
+		resolvers: &resolvers,
+	}
+	if err := initSQLPools(driver, "", map[string]*SQLConfig{
+		"main": {
+			DataSourceName: filepath.Join(dir, "primary.db"),
+			Replicas:       []string{filepath.Join(dir, "replica0.db"), filepath.Join(dir, "replica1.db")},
+			MaxOpenConn:    3,
+		},
+		"single": {DataSourceName: filepath.Join(dir, "single.db")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	replicas := sqlReplicas(driver)
+	if len(replicas) != 1 || len(replicas["main"]) != 2 {
+		t.Fatalf("replicas = %v", replicas)
+	}
+	primary, err := pools["main"].DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas["main"][0] == primary || replicas["main"][1] == primary || replicas["main"][0] == replicas["main"][1] {
+		t.Fatal("replica snapshot includes the primary or duplicates")
+	}
+	for _, replica := range replicas["main"] {
+		if n := replica.Stats().MaxOpenConnections; n != 3 {
+			t.Fatalf("replica max open connections = %d, want 3", n)
+		}
+	}
+
+	if err := closeSQLPools(driver); err != nil {
+		t.Fatal(err)
+	}
+	for _, replica := range replicas["main"] {
+		if err := replica.Ping(); err == nil {
+			t.Fatal("replica not closed with its pool")
+		}
+	}
+}
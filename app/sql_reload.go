@@ -0,0 +1,276 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// 重建连接池默认参数
+const (
+	defaultDrainTimeout  = time.Second * 30
+	defaultVerifyTimeout = time.Second * 5
+	drainPollInterval    = time.Millisecond * 200
+)
+
+// ReloadMySQLPool 使用新配置重建 MySQL 命名连接池，验证通过后替换，旧连接池在查询结束后关闭；
+// 旧连接池关闭后不可再用，长期持有连接池的调用方应在每次使用时通过 GetMySQLPool 获取
+func ReloadMySQLPool(name string, config *SQLConfig) error {
+	return reloadSQLPool(mysqlDriver, name, config)
+}
+
+// ReloadPgSQLPool 使用新配置重建 Postgres 命名连接池，验证通过后替换，旧连接池在查询结束后关闭；
+// 旧连接池关闭后不可再用，长期持有连接池的调用方应在每次使用时通过 GetPgSQLPool 获取
+func ReloadPgSQLPool(name string, config *SQLConfig) error {
+	return reloadSQLPool(postgresDriver, name, config)
+}
+
+// ReloadMySQLConfig 重新读取 MySQL 配置文件，重建配置变化的连接池
+func ReloadMySQLConfig() error {
+	return reloadSQLConfig(mysqlDriver)
+}
+
+// ReloadPgSQLConfig 重新读取 Postgres 配置文件，重建配置变化的连接池
+func ReloadPgSQLConfig() error {
+	return reloadSQLConfig(postgresDriver)
+}
+
+// reloadSQLPool 打开并验证新连接池，替换后排空旧连接池
+func reloadSQLPool(driver *sqlDriver, name string, config *SQLConfig) error {
+	drain, err := parseDuration(config.DrainTimeout, defaultDrainTimeout)
+	if err != nil {
+		return err
+	}
+	db, resolver, err := openSQLPool(driver, name, config)
+	if err != nil {
+		return fmt.Errorf("%s pool %s reload failed: %v", driver.name, name, err)
+	}
+	if err := verifySQLPool(db); err != nil {
+		closeOpenedPool(db, resolver)
+		return fmt.Errorf("%s pool %s reload verify failed: %v", driver.name, name, err)
+	}
+
+	sqlPoolLock.Lock()
+	if err := useSQLPlugins(driver.name, name, db); err != nil {
+		sqlPoolLock.Unlock()
+		closeOpenedPool(db, resolver)
+		return err
+	}
+	if *driver.pools == nil {
+		*driver.pools = map[string]*gorm.DB{}
+	}
+	if *driver.resolvers == nil {
+		*driver.resolvers = map[string]*dbresolver.DBResolver{}
+	}
+	if driver.configs == nil {
+		driver.configs = map[string]*SQLConfig{}
+	}
+	driver.supersede(name) // 仍在重试的旧配置连接不再登记
+	old, oldResolver := (*driver.pools)[name], (*driver.resolvers)[name]
+	(*driver.pools)[name] = db
+	if resolver != nil {
+		(*driver.resolvers)[name] = resolver
+	} else {
+		delete(*driver.resolvers, name)
+	}
+	driver.configs[name] = config
+	sqlPoolLock.Unlock()
+
+	setPoolStatus(driver.name, name, PoolReady, 1, nil)
+	logger.Info("%s pool %s reloaded", driver.name, name)
+	if old != nil {
+		go drainSQLPool(driver.name, name, old, oldResolver, drain)
+	}
+	return nil
+}
+
+// verifySQLPool 确认新连接池可用
+func verifySQLPool(db *gorm.DB) error {
+	PQ, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultVerifyTimeout)
+	defer cancel()
+	return PQ.PingContext(ctx)
+}
+
+// drainSQLPool 等待旧连接池中的连接归还或超时后关闭
+func drainSQLPool(driverName, name string, db *gorm.DB, resolver *dbresolver.DBResolver, timeout time.Duration) {
+	PQ, err := db.DB()
+	if err != nil {
+		return
+	}
+	deadline := time.Now().Add(timeout)
+	for PQ.Stats().InUse > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	if inUse := PQ.Stats().InUse; inUse > 0 {
+		logger.Warn("%s pool %s closing old pool with %d connection(s) in use", driverName, name, inUse)
+	}
+	if resolver != nil {
+		_ = closeReplicas(resolver)
+	}
+	if err := PQ.Close(); err != nil {
+		logger.Warn("%s pool %s close old pool failed: %v", driverName, name, err)
+	}
+}
+
+// reloadSQLConfig 重新读取配置文件：新增的连接池建立连接，配置变化的重建，删除的关闭
+func reloadSQLConfig(driver *sqlDriver) error {
+	sqlPoolLock.RLock()
+	path := driver.configPath
+	current := make(map[string]*SQLConfig, len(driver.configs))
+	for name, config := range driver.configs {
+		current[name] = config
+	}
+	sqlPoolLock.RUnlock()
+	if path == "" {
+		return fmt.Errorf("%s pools not initialized from config file", driver.name)
+	}
+	list, err := driver.parse(path)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(list))
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []string
+	for _, name := range names {
+		if reflect.DeepEqual(current[name], list[name]) {
+			continue
+		}
+		if err := reloadSQLPool(driver, name, list[name]); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for name := range current {
+		if _, ok := list[name]; !ok {
+			removeSQLPool(driver, name)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// removeSQLPool 移除配置中已删除的连接池
+func removeSQLPool(driver *sqlDriver, name string) {
+	sqlPoolLock.Lock()
+	db, resolver := (*driver.pools)[name], (*driver.resolvers)[name]
+	drain := defaultDrainTimeout
+	if config, ok := driver.configs[name]; ok {
+		if d, err := parseDuration(config.DrainTimeout, defaultDrainTimeout); err == nil {
+			drain = d
+		}
+	}
+	driver.supersede(name)
+	delete(*driver.pools, name)
+	delete(*driver.resolvers, name)
+	delete(driver.configs, name)
+	sqlPoolLock.Unlock()
+	setPoolStatus(driver.name, name, PoolClosed, 0, nil)
+	logger.Info("%s pool %s removed", driver.name, name)
+	if db != nil {
+		go drainSQLPool(driver.name, name, db, resolver, drain)
+	}
+}
+
+// fileStamp 文件修改时间与大小
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFile 读取文件状态，跟随符号链接 (Kubernetes Secret 通过替换链接更新)
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+// sqlWatcher 配置文件与密码文件监视器
+type sqlWatcher struct {
+	stamps map[string]fileStamp
+}
+
+// WatchSQLConfig 定期检查 MySQL / Postgres 配置文件与密码文件，变化时重建对应连接池，返回停止函数
+func WatchSQLConfig(interval time.Duration) (stop func()) {
+	w := &sqlWatcher{stamps: map[string]fileStamp{}}
+	w.check(false)
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				w.check(true)
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// changed 记录文件状态，返回是否与上次不同
+func (w *sqlWatcher) changed(path string) bool {
+	stamp := statFile(path)
+	old, ok := w.stamps[path]
+	w.stamps[path] = stamp
+	return ok && old != stamp
+}
+
+// check 检查文件变化，reload 为 false 时只记录状态
+func (w *sqlWatcher) check(reload bool) {
+	for _, driver := range []*sqlDriver{mysqlDriver, postgresDriver} {
+		sqlPoolLock.RLock()
+		path := driver.configPath
+		configs := make(map[string]*SQLConfig, len(driver.configs))
+		for name, config := range driver.configs {
+			configs[name] = config
+		}
+		sqlPoolLock.RUnlock()
+		if path == "" {
+			continue
+		}
+		if w.changed(path) && reload {
+			if err := reloadSQLConfig(driver); err != nil {
+				w.stamps[path] = fileStamp{} // 下次检查时重试
+				logger.Error("%s config reload failed: %v", driver.name, err)
+			}
+			continue // 密码文件在下次检查时处理
+		}
+		for name, config := range configs {
+			if config.PasswordFile == "" {
+				continue
+			}
+			if w.changed(config.PasswordFile) && reload {
+				if err := reloadSQLPool(driver, name, config); err != nil {
+					w.stamps[config.PasswordFile] = fileStamp{} // 下次检查时重试
+					logger.Error("%v", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+	"github.com/MetaverseTopDJ/Scaffold/util"
+
+	"gorm.io/gorm"
+)
+
+// 租户隔离方式
+const (
+	TenantModePool     = "pool"     // 每个租户使用已配置的命名连接池
+	TenantModeSchema   = "schema"   // 按模板创建连接池，Postgres 设置 search_path，MySQL 切换数据库
+	TenantModeDatabase = "database" // 按模板创建连接池，切换数据库
+)
+
+// 默认租户参数
+const (
+	defaultTenantIdleTimeout = time.Minute * 30
+	defaultTenantFormat      = "%s"
+	defaultTenantEvictGrace  = time.Minute // 最近使用过的连接池不因超过 max_pools 被关闭
+)
+
+var (
+	ErrTenantAbsent  = errors.New("tenant not found in context")
+	ErrTenantInvalid = errors.New("invalid tenant id")
+)
+
+// tenantIDPattern 租户 ID 会拼入连接池名、数据源与 search_path，仅允许字母、数字与下划线
+var tenantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,63}$`)
+
+// TenantConfig 单个租户的覆盖配置
+type TenantConfig struct {
+	Pool     string `mapstructure:"pool"`     // 使用已配置的命名连接池，优先于模板
+	Schema   string `mapstructure:"schema"`   // schema 模式下的 schema 名
+	Database string `mapstructure:"database"` // 数据库名
+}
+
+// TenancyConfig 多租户配置
+type TenancyConfig struct {
+	Driver         string                   `mapstructure:"driver"`          // 连接池驱动 mysql / postgres，默认 postgres
+	Mode           string                   `mapstructure:"mode"`            // 隔离方式 pool / schema / database，默认 schema
+	PoolFormat     string                   `mapstructure:"pool_format"`     // pool 模式下的连接池名格式 如 tenant_%s，默认 %s
+	SchemaFormat   string                   `mapstructure:"schema_format"`   // schema 名格式，默认 %s
+	DatabaseFormat string                   `mapstructure:"database_format"` // 数据库名格式，默认 %s
+	IdleTimeout    string                   `mapstructure:"idle_timeout"`    // 租户连接池空闲多久后关闭 如 30m，默认 30m
+	MaxPools       int                      `mapstructure:"max_pools"`       // 最多缓存的租户连接池数，超过时关闭最久未使用的 (1m 内使用过的除外)，0 表示不限制
+	Template       SQLConfig                `mapstructure:"template"`        // 租户连接池模板，需使用 host 等独立字段，drain_timeout 为关闭时等待查询结束的最长时间
+	Tenants        map[string]*TenantConfig `mapstructure:"tenants"`         // 租户覆盖配置，键不区分大小写
+}
+
+// TenantResolver 从上下文中解析租户
+type TenantResolver func(ctx context.Context) (string, error)
+
+// tenantPool 按模板创建的租户连接池
+type tenantPool struct {
+	ready    chan struct{} // 连接完成后关闭
+	db       *gorm.DB
+	err      error
+	lastUsed int64 // 最近获取或查询的时间 UnixNano，原子访问
+}
+
+// touch 记录使用时间
+func (p *tenantPool) touch() {
+	atomic.StoreInt64(&p.lastUsed, time.Now().UnixNano())
+}
+
+// usedSince 在 t 之后使用过
+func (p *tenantPool) usedSince(t time.Time) bool {
+	return atomic.LoadInt64(&p.lastUsed) > t.UnixNano()
+}
+
+// track 注册回调，每次查询时更新使用时间
+func (p *tenantPool) track(db *gorm.DB) error {
+	touch := func(*gorm.DB) { p.touch() }
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Create().Before("*").Register("scaffold:tenant_touch", touch),
+		callbacks.Query().Before("*").Register("scaffold:tenant_touch", touch),
+		callbacks.Update().Before("*").Register("scaffold:tenant_touch", touch),
+		callbacks.Delete().Before("*").Register("scaffold:tenant_touch", touch),
+		callbacks.Row().Before("*").Register("scaffold:tenant_touch", touch),
+		callbacks.Raw().Before("*").Register("scaffold:tenant_touch", touch),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tenancy 多租户连接路由
+type Tenancy struct {
+	driver       *sqlDriver
+	config       *TenancyConfig
+	resolver     TenantResolver
+	idleTimeout  time.Duration
+	drainTimeout time.Duration
+	evictGrace   time.Duration // 超过 max_pools 时不关闭在此时间内使用过的连接池
+
+	mu     sync.Mutex
+	pools  map[string]*tenantPool
+	stop   chan struct{}
+	closed bool
+}
+
+var (
+	tenancyLock sync.RWMutex
+	tenancy     *Tenancy
+)
+
+// InitTenancyConfig 加载多租户配置
+func InitTenancyConfig(path string) error {
+	config := &TenancyConfig{}
+	if err := util.ParseConfig(path, config); err != nil {
+		return err
+	}
+	t, err := NewTenancy(config, nil)
+	if err != nil {
+		return err
+	}
+	SetTenancy(t)
+	return nil
+}
+
+// SetTenancy 设置默认多租户路由，关闭之前的路由
+func SetTenancy(t *Tenancy) {
+	tenancyLock.Lock()
+	old := tenancy
+	tenancy = t
+	tenancyLock.Unlock()
+	if old != nil && old != t {
+		old.Close()
+	}
+}
+
+// GetTenancy 获取默认多租户路由
+func GetTenancy() (*Tenancy, error) {
+	tenancyLock.RLock()
+	defer tenancyLock.RUnlock()
+	if tenancy == nil {
+		return nil, errors.New("tenancy not initialized")
+	}
+	return tenancy, nil
+}
+
+// CloseTenancy 关闭默认多租户路由的所有租户连接池
+func CloseTenancy() {
+	SetTenancy(nil)
+}
+
+// NewTenancy 创建多租户路由，resolver 为空时使用 TenantFromContext
+func NewTenancy(config *TenancyConfig, resolver TenantResolver) (*Tenancy, error) {
+	t := &Tenancy{config: config, resolver: resolver, evictGrace: defaultTenantEvictGrace, pools: map[string]*tenantPool{}}
+	switch strings.ToLower(config.Driver) {
+	case "", "postgres", "postgresql":
+		t.driver = postgresDriver
+	case "mysql":
+		t.driver = mysqlDriver
+	default:
+		return nil, fmt.Errorf("tenancy: invalid driver %q", config.Driver)
+	}
+	switch strings.ToLower(config.Mode) {
+	case "":
+		config.Mode = TenantModeSchema
+	case TenantModePool, TenantModeSchema, TenantModeDatabase:
+		config.Mode = strings.ToLower(config.Mode)
+	default:
+		return nil, fmt.Errorf("tenancy: invalid mode %q", config.Mode)
+	}
+	if config.Mode != TenantModePool && config.Template.DataSourceName != "" {
+		return nil, errors.New("tenancy: template requires host fields instead of data_source_name")
+	}
+	idleTimeout, err := parseDuration(config.IdleTimeout, defaultTenantIdleTimeout)
+	if err != nil {
+		return nil, err
+	}
+	t.idleTimeout = idleTimeout
+	if t.drainTimeout, err = parseDuration(config.Template.DrainTimeout, defaultDrainTimeout); err != nil {
+		return nil, err
+	}
+	tenants := make(map[string]*TenantConfig, len(config.Tenants))
+	for id, tenant := range config.Tenants {
+		tenants[strings.ToLower(id)] = tenant
+	}
+	config.Tenants = tenants
+	if t.resolver == nil {
+		t.resolver = func(ctx context.Context) (string, error) {
+			if id, ok := TenantFromContext(ctx); ok {
+				return id, nil
+			}
+			return "", ErrTenantAbsent
+		}
+	}
+	if config.Mode != TenantModePool && idleTimeout > 0 {
+		t.stop = make(chan struct{})
+		go t.evictLoop(t.stop)
+	}
+	return t, nil
+}
+
+// DB 获取上下文中租户的连接
+func (t *Tenancy) DB(ctx context.Context) (*gorm.DB, error) {
+	id, err := t.resolver(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db, err := t.TenantDB(id)
+	if err != nil {
+		return nil, err
+	}
+	return db.WithContext(ctx), nil
+}
+
+// TenantDB 获取租户的连接池，按模板创建的连接池会被缓存
+func (t *Tenancy) TenantDB(id string) (*gorm.DB, error) {
+	if !tenantIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: %q", ErrTenantInvalid, id)
+	}
+	id = strings.ToLower(id)
+	tenant := t.config.Tenants[id]
+	if tenant != nil && tenant.Pool != "" {
+		return getSQLPool(t.driver, tenant.Pool)
+	}
+	if t.config.Mode == TenantModePool {
+		return getSQLPool(t.driver, tenantName(t.config.PoolFormat, id))
+	}
+
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, errors.New("tenancy closed")
+	}
+	pool, ok := t.pools[id]
+	if ok {
+		pool.touch()
+		t.mu.Unlock()
+		<-pool.ready
+		return pool.db, pool.err
+	}
+	pool = &tenantPool{ready: make(chan struct{})}
+	pool.touch()
+	t.pools[id] = pool
+	t.evictOverflow()
+	t.mu.Unlock()
+
+	pool.db, pool.err = t.open(id, tenant, pool)
+	close(pool.ready)
+	if pool.err != nil {
+		t.mu.Lock()
+		if t.pools[id] == pool {
+			delete(t.pools, id) // 下次请求重新连接
+		}
+		t.mu.Unlock()
+		logger.Error("tenant %s pool connect failed: %v", id, pool.err)
+	}
+	return pool.db, pool.err
+}
+
+// open 按模板为租户创建连接池
+func (t *Tenancy) open(id string, tenant *TenantConfig, pool *tenantPool) (*gorm.DB, error) {
+	config := t.config.Template
+	config.Replicas = nil // 副本数据源无法按租户切换
+	config.Params = make(map[string]string, len(t.config.Template.Params)+1)
+	for key, value := range t.config.Template.Params {
+		config.Params[key] = value
+	}
+	if tenant == nil {
+		tenant = &TenantConfig{}
+	}
+	database := tenant.Database
+	if database == "" && t.config.Mode == TenantModeDatabase {
+		database = tenantName(t.config.DatabaseFormat, id)
+	}
+	if t.config.Mode == TenantModeSchema {
+		schema := tenant.Schema
+		if schema == "" {
+			schema = tenantName(t.config.SchemaFormat, id)
+		}
+		if t.driver == mysqlDriver {
+			database = schema // MySQL 中 schema 即数据库
+		} else {
+			config.Params["search_path"] = schema
+		}
+	}
+	if database != "" {
+		config.Database = database
+	}
+	name := "tenant_" + id
+	db, resolver, err := openSQLPool(t.driver, name, &config)
+	if err != nil {
+		return nil, err
+	}
+	sqlPoolLock.RLock()
+	err = useSQLPlugins(t.driver.name, name, db)
+	sqlPoolLock.RUnlock()
+	if err == nil {
+		err = pool.track(db)
+	}
+	if err != nil {
+		closeOpenedPool(db, resolver)
+		return nil, err
+	}
+	logger.Info("tenant %s %s pool connected", id, t.driver.name)
+	return db, nil
+}
+
+// tenantName 按格式生成租户的连接池、schema 或数据库名
+func tenantName(format, id string) string {
+	if format == "" {
+		format = defaultTenantFormat
+	}
+	return fmt.Sprintf(format, id)
+}
+
+// Tenants 已缓存连接池的租户
+func (t *Tenancy) Tenants() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]string, 0, len(t.pools))
+	for id := range t.pools {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Evict 关闭租户的连接池，进行中的查询完成后关闭
+func (t *Tenancy) Evict(id string) {
+	t.mu.Lock()
+	pool, ok := t.pools[strings.ToLower(id)]
+	delete(t.pools, strings.ToLower(id))
+	t.mu.Unlock()
+	if ok {
+		go t.closePool(id, pool)
+	}
+}
+
+// EvictIdle 关闭空闲超过 idle 的租户连接池，进行中的查询完成后关闭，返回关闭的数量
+func (t *Tenancy) EvictIdle(idle time.Duration) int {
+	deadline := time.Now().Add(-idle)
+	t.mu.Lock()
+	evicted := map[string]*tenantPool{}
+	for id, pool := range t.pools {
+		if !pool.usedSince(deadline) {
+			evicted[id] = pool
+			delete(t.pools, id)
+		}
+	}
+	t.mu.Unlock()
+	for id, pool := range evicted {
+		go t.closePool(id, pool)
+	}
+	return len(evicted)
+}
+
+// evictOverflow 超过 max_pools 时关闭最久未使用的连接池，调用方需持有 t.mu；
+// evictGrace 内使用过的连接池可能刚交给调用方尚未查询，暂时允许超出，之后由 evictLoop 或下次创建连接池时关闭
+func (t *Tenancy) evictOverflow() {
+	grace := time.Now().Add(-t.evictGrace)
+	for t.config.MaxPools > 0 && len(t.pools) > t.config.MaxPools {
+		var oldest string
+		for id, pool := range t.pools {
+			if pool.usedSince(grace) {
+				continue
+			}
+			if oldest == "" || atomic.LoadInt64(&pool.lastUsed) < atomic.LoadInt64(&t.pools[oldest].lastUsed) {
+				oldest = id
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		pool := t.pools[oldest]
+		delete(t.pools, oldest)
+		go t.closePool(oldest, pool)
+	}
+}
+
+// evictLoop 定期关闭空闲的租户连接池
+func (t *Tenancy) evictLoop(stop chan struct{}) {
+	interval := t.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if n := t.EvictIdle(t.idleTimeout); n > 0 {
+				logger.Info("tenancy evicted %d idle pool(s)", n)
+			}
+			t.mu.Lock()
+			t.evictOverflow()
+			t.mu.Unlock()
+		}
+	}
+}
+
+// Close 停止回收并关闭所有租户连接池，等待进行中的查询结束，命名连接池不受影响
+func (t *Tenancy) Close() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.closed = true
+	if t.stop != nil {
+		close(t.stop)
+	}
+	pools := t.pools
+	t.pools = map[string]*tenantPool{}
+	t.mu.Unlock()
+	var wg sync.WaitGroup
+	for id, pool := range pools {
+		wg.Add(1)
+		go func(id string, pool *tenantPool) {
+			defer wg.Done()
+			t.closePool(id, pool)
+		}(id, pool)
+	}
+	wg.Wait()
+}
+
+// closePool 等待连接完成，再等待进行中的查询结束或超过 drainTimeout 后关闭租户连接池
+func (t *Tenancy) closePool(id string, pool *tenantPool) {
+	<-pool.ready
+	if pool.db == nil {
+		return
+	}
+	drainSQLPool(t.driver.name, "tenant_"+id, pool.db, nil, t.drainTimeout)
+}
+
+type tenantContextKey struct{}
+
+// WithTenant 在上下文中设置租户
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, id)
+}
+
+// TenantFromContext 获取上下文中的租户
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	id, ok := ctx.Value(tenantContextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// GetTenantDB 根据上下文中的租户获取连接
+func GetTenantDB(ctx context.Context) (*gorm.DB, error) {
+	t, err := GetTenancy()
+	if err != nil {
+		return nil, err
+	}
+	return t.DB(ctx)
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestEvictDrainsInUsePool(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tenant.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	PQ, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready := make(chan struct{})
+	close(ready)
+	tenancy := &Tenancy{
+		driver:       postgresDriver,
+		config:       &TenancyConfig{Mode: TenantModeSchema},
+		drainTimeout: time.Second * 5,
+		pools:        map[string]*tenantPool{"acme": {ready: ready, db: db, lastUsed: time.Now().Add(-time.Hour).UnixNano()}},
+	}
+
+	conn, err := PQ.Conn(context.Background()) // 进行中的查询
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := tenancy.EvictIdle(time.Minute); n != 1 {
+		t.Fatalf("evicted %d, want 1", n)
+	}
+	time.Sleep(drainPollInterval * 2)
+	if err := conn.PingContext(context.Background()); err != nil {
+		t.Fatalf("in-use connection closed before drain: %v", err)
+	}
+	if err := PQ.Ping(); err != nil {
+		t.Fatalf("pool closed before drain: %v", err)
+	}
+
+	_ = conn.Close()
+	deadline := time.Now().Add(time.Second * 2)
+	for PQ.Ping() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("pool not closed after drain")
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+// newSQLiteTenancy 创建按数据库隔离的租户路由，每个租户使用独立的 SQLite 文件
+func newSQLiteTenancy(t *testing.T, maxPools int) *Tenancy {
+	t.Helper()
+	dir := t.TempDir()
+	driver := &sqlDriver{
+		name: "test",
+		dsn:  func(config *SQLConfig) (string, error) { return filepath.Join(dir, config.Database+".db"), nil },
+		dialector: func(name, dsn string, config *SQLConfig) (gorm.Dialector, error) {
+			return sqlite.Open(dsn), nil
+		},
+	}
+	tenancy, err := NewTenancy(&TenancyConfig{Mode: TenantModeDatabase, MaxPools: maxPools, IdleTimeout: "0s"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenancy.driver, tenancy.drainTimeout = driver, time.Second
+	t.Cleanup(tenancy.Close)
+	return tenancy
+}
+
+func TestEvictOverflowKeepsRecentlyUsedPool(t *testing.T) {
+	tenancy := newSQLiteTenancy(t, 1)
+	a, err := tenancy.TenantDB("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tenancy.TenantDB("b"); err != nil {
+		t.Fatal(err)
+	}
+	// a 刚交给调用方还未查询，不能因超过 max_pools 被关闭
+	time.Sleep(drainPollInterval * 2)
+	if err := a.Exec("SELECT 1").Error; err != nil {
+		t.Fatalf("pool of tenant a closed after handed out: %v", err)
+	}
+
+	tenancy.evictGrace = time.Millisecond * 100
+	time.Sleep(tenancy.evictGrace / 2)
+	if err := a.Exec("SELECT 1").Error; err != nil { // 查询更新使用时间
+		t.Fatal(err)
+	}
+	time.Sleep(tenancy.evictGrace / 2)
+	if _, err := tenancy.TenantDB("c"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := tenancy.Tenants(); len(ids) != 2 || ids[0] != "a" || ids[1] != "c" {
+		t.Fatalf("tenants = %v, want [a c]", ids)
+	}
+}
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+// 默认重试参数
+const (
+	defaultTxMaxRetries = 3
+	defaultTxBackoff    = time.Millisecond * 50
+	defaultTxMaxBackoff = time.Second
+)
+
+// TxOptions 事务选项
+type TxOptions struct {
+	Isolation  sql.IsolationLevel // 隔离级别
+	ReadOnly   bool               // 只读事务
+	MaxRetries int                // 序列化失败与死锁的最大重试次数，小于 0 时不重试
+	Backoff    time.Duration      // 初始退避时间
+	MaxBackoff time.Duration      // 最大退避时间
+}
+
+// TxFunc 事务函数，ctx 中携带当前事务，嵌套调用 Transaction 时使用保存点
+type TxFunc func(ctx context.Context, tx *gorm.DB) error
+
+type txContextKey struct{}
+
+// txState 上下文中的事务状态，不同连接池的事务通过 parent 串联
+type txState struct {
+	tx     *gorm.DB
+	pool   gorm.ConnPool // 所属连接池，同一连接池的会话与事务共享
+	depth  int
+	parent *txState
+}
+
+// findTx 查找上下文中属于 db 所在连接池的事务
+func findTx(ctx context.Context, db *gorm.DB) (*txState, bool) {
+	state, _ := ctx.Value(txContextKey{}).(*txState)
+	pool := connPool(db)
+	for ; state != nil; state = state.parent {
+		if state.pool == pool {
+			return state, true
+		}
+	}
+	return nil, false
+}
+
+// connPool db 所在的连接池，会话 (含 WithContext) 复制 Config 但共享 ConnPool
+func connPool(db *gorm.DB) gorm.ConnPool {
+	if stmt, ok := db.Config.ConnPool.(*gorm.PreparedStmtDB); ok {
+		return stmt.ConnPool
+	}
+	return db.Config.ConnPool
+}
+
+// TxFromContext 获取上下文中属于 db 所在连接池的事务，其他连接池的事务不会返回
+func TxFromContext(ctx context.Context, db *gorm.DB) (*gorm.DB, bool) {
+	if ctx == nil || db == nil {
+		return nil, false
+	}
+	state, ok := findTx(ctx, db)
+	if !ok {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+// PgSQLTransaction 在指定 Postgres 连接池上执行事务
+func PgSQLTransaction(ctx context.Context, name string, fc TxFunc, opts ...*TxOptions) error {
+	db, err := GetPgSQLPool(name)
+	if err != nil {
+		return err
+	}
+	return Transaction(ctx, db, fc, opts...)
+}
+
+// MySQLTransaction 在指定 MySQL 连接池上执行事务
+func MySQLTransaction(ctx context.Context, name string, fc TxFunc, opts ...*TxOptions) error {
+	db, err := GetMySQLPool(name)
+	if err != nil {
+		return err
+	}
+	return Transaction(ctx, db, fc, opts...)
+}
+
+// Transaction 执行事务，序列化失败与死锁时按退避策略重试；上下文中已有同一连接池的事务时创建保存点，其他连接池开启独立事务
+func Transaction(ctx context.Context, db *gorm.DB, fc TxFunc, opts ...*TxOptions) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if state, ok := findTx(ctx, db); ok {
+		return savepoint(ctx, state, fc)
+	}
+	parent, _ := ctx.Value(txContextKey{}).(*txState)
+
+	opt := &TxOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		opt = opts[0]
+	}
+	maxRetries, backoff, maxBackoff := opt.MaxRetries, opt.Backoff, opt.MaxBackoff
+	if maxRetries == 0 {
+		maxRetries = defaultTxMaxRetries
+	}
+	if backoff <= 0 {
+		backoff = defaultTxBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultTxMaxBackoff
+	}
+	txOptions := &sql.TxOptions{Isolation: opt.Isolation, ReadOnly: opt.ReadOnly}
+
+	for retry := 0; ; retry++ {
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fc(context.WithValue(ctx, txContextKey{}, &txState{tx: tx, pool: connPool(db), parent: parent}), tx)
+		}, txOptions)
+		if err == nil {
+			if retry > 0 {
+				logger.Log.TagInfo(logger.TraceFromContext(ctx), txTag(db, false), txFields("transaction committed after retry", retry, nil))
+			}
+			return nil
+		}
+		if !IsRetryableTxError(err) || retry >= maxRetries {
+			if retry > 0 {
+				logger.Log.TagError(logger.TraceFromContext(ctx), txTag(db, true), txFields("transaction retry exhausted", retry, err))
+			}
+			return err
+		}
+		wait := backoff << uint(retry)
+		if wait > maxBackoff || wait <= 0 {
+			wait = maxBackoff
+		}
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait)/2+1)) // 抖动
+		logger.Log.TagWarn(logger.TraceFromContext(ctx), txTag(db, true), txFields("transaction retry", retry+1, err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// savepoint 嵌套事务使用保存点，失败时回滚到保存点
+func savepoint(ctx context.Context, owner *txState, fc TxFunc) (err error) {
+	parent, _ := ctx.Value(txContextKey{}).(*txState)
+	state := &txState{tx: owner.tx, pool: owner.pool, depth: owner.depth + 1, parent: parent}
+	name := fmt.Sprintf("sp_%d", state.depth)
+	if err = state.tx.SavePoint(name).Error; err != nil {
+		return err
+	}
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			state.tx.RollbackTo(name)
+		}
+	}()
+	err = fc(context.WithValue(ctx, txContextKey{}, state), state.tx)
+	panicked = false
+	return err
+}
+
+// IsRetryableTxError 判断是否为可重试的事务错误 (序列化失败、死锁、锁等待超时)
+func IsRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1213 || myErr.Number == 1205
+	}
+	return false
+}
+
+// txTag 根据数据库类型选择日志标签
+func txTag(db *gorm.DB, failed bool) string {
+	switch db.Dialector.Name() {
+	case "mysql":
+		if failed {
+			return logger.DLTagMySQLFailed
+		}
+		return logger.DLTagMySQLSuccess
+	case "postgres":
+		if failed {
+			return logger.DLTagPgSQLFailed
+		}
+		return logger.DLTagPgSQLSuccess
+	}
+	return logger.DLTagUndefined
+}
+
+// txFields 事务重试日志字段
+func txFields(msg string, retry int, err error) map[string]interface{} {
+	m := map[string]interface{}{
+		"msg":   msg,
+		"retry": retry,
+	}
+	if err != nil {
+		m["error"] = err.Error()
+	}
+	return m
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type txItem struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+}
+
+func openTxTestDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&txItem{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func countTxItems(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(&txItem{}).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestTransactionOtherPoolIsIndependent(t *testing.T) {
+	a, b := openTxTestDB(t, "a.db"), openTxTestDB(t, "b.db")
+	err := Transaction(context.Background(), a, func(ctx context.Context, tx *gorm.DB) error {
+		if err := tx.Create(&txItem{Name: "a"}).Error; err != nil {
+			return err
+		}
+		if err := Transaction(ctx, b, func(ctx context.Context, tx *gorm.DB) error {
+			return tx.Create(&txItem{Name: "b"}).Error
+		}); err != nil {
+			return err
+		}
+		// 回到同一连接池时仍嵌套在外层事务中
+		return Transaction(ctx, a, func(ctx context.Context, tx *gorm.DB) error {
+			if err := tx.Create(&txItem{Name: "a2"}).Error; err != nil {
+				return err
+			}
+			return errors.New("rollback to savepoint")
+		})
+	})
+	if err == nil {
+		t.Fatal("expected error from nested transaction")
+	}
+	if n := countTxItems(t, a); n != 0 {
+		t.Fatalf("pool a has %d rows, want 0", n)
+	}
+	if n := countTxItems(t, b); n != 1 {
+		t.Fatalf("pool b has %d rows, want 1", n)
+	}
+}
+
+func TestTransactionSavepoint(t *testing.T) {
+	a := openTxTestDB(t, "a.db")
+	err := Transaction(context.Background(), a, func(ctx context.Context, tx *gorm.DB) error {
+		if err := tx.Create(&txItem{Name: "outer"}).Error; err != nil {
+			return err
+		}
+		_ = Transaction(ctx, a, func(ctx context.Context, tx *gorm.DB) error {
+			tx.Create(&txItem{Name: "inner"})
+			return errors.New("rollback inner")
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countTxItems(t, a); n != 1 {
+		t.Fatalf("got %d rows, want 1", n)
+	}
+}
+
+func TestTxFromContextMatchesPool(t *testing.T) {
+	a, b := openTxTestDB(t, "a.db"), openTxTestDB(t, "b.db")
+	if _, ok := TxFromContext(context.Background(), a); ok {
+		t.Fatal("found transaction outside Transaction")
+	}
+	err := Transaction(context.Background(), a, func(ctx context.Context, txA *gorm.DB) error {
+		return Transaction(ctx, b, func(ctx context.Context, txB *gorm.DB) error {
+			if tx, ok := TxFromContext(ctx, a); !ok || tx != txA {
+				t.Error("pool a resolved to another transaction")
+			}
+			if tx, ok := TxFromContext(ctx, b); !ok || tx != txB {
+				t.Error("pool b resolved to another transaction")
+			}
+			// 同一连接池的会话也属于该连接池
+			if _, ok := TxFromContext(ctx, a.WithContext(context.Background())); !ok {
+				t.Error("session of pool a not matched")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,248 @@
+package apptest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+	"github.com/MetaverseTopDJ/Scaffold/util"
+
+	"github.com/gomodule/redigo/redis"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+const defaultBaseConfig = `[base]
+env = "test"
+debug_mode = "debug"
+time_location = "UTC"
+`
+
+// Option 测试环境选项
+type Option func(*options)
+
+type options struct {
+	pgsql  []string
+	mysql  []string
+	redis  []string
+	files  map[string]string
+	logSQL bool
+}
+
+// WithPgSQL 创建 SQLite 实现的 Postgres 命名连接池
+func WithPgSQL(names ...string) Option {
+	return func(o *options) {
+		o.pgsql = append(o.pgsql, names...)
+	}
+}
+
+// WithMySQL 创建 SQLite 实现的 MySQL 命名连接池
+func WithMySQL(names ...string) Option {
+	return func(o *options) {
+		o.mysql = append(o.mysql, names...)
+	}
+}
+
+// WithRedis 创建连接进程内 Redis 服务的命名连接池
+func WithRedis(names ...string) Option {
+	return func(o *options) {
+		o.redis = append(o.redis, names...)
+	}
+}
+
+// WithConfig 写入配置文件，name 不含扩展名，如 base，content 为 toml 内容
+func WithConfig(name, content string) Option {
+	return func(o *options) {
+		o.files[name] = content
+	}
+}
+
+// WithSQLLog 将 SQL 语句写入日志
+func WithSQLLog() Option {
+	return func(o *options) {
+		o.logSQL = true
+	}
+}
+
+// App 测试环境，创建时替换 app 包的全局配置与连接池，测试结束时自动恢复
+type App struct {
+	Dir   string       // 配置文件目录
+	Redis *RedisServer // 进程内 Redis 服务
+	Logs  *LogCapture  // 日志记录
+
+	t testing.TB
+}
+
+// New 创建测试环境，同一时间只能存在一个，使用 New 的测试不能并行执行
+func New(t testing.TB, opts ...Option) *App {
+	t.Helper()
+	o := &options{files: map[string]string{"base": defaultBaseConfig}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	a := &App{Dir: filepath.Join(t.TempDir(), "test"), Logs: &LogCapture{}, t: t}
+	a.saveGlobals()
+
+	server, err := NewRedisServer()
+	if err != nil {
+		t.Fatalf("apptest: start redis server: %v", err)
+	}
+	a.Redis = server
+	t.Cleanup(func() { _ = server.Close() })
+
+	if len(o.redis) > 0 {
+		o.files["redis"] = redisConfig(o.redis, server.Addr())
+	}
+	if err := a.writeConfig(o.files); err != nil {
+		t.Fatalf("apptest: write config: %v", err)
+	}
+
+	logger.Register(a.Logs)
+	t.Cleanup(logger.Close) // 等待日志写入完成并重置默认 logger，仍在运行的后台任务之后的日志被丢弃
+
+	if util.LocalIP == nil {
+		util.SetLocalIPs()
+	}
+	if util.LocalIP == nil {
+		util.LocalIP = net.IPv4(127, 0, 0, 1)
+	}
+	if err := util.ParseConfigPath(a.Dir + "/"); err != nil {
+		t.Fatalf("apptest: %v", err)
+	}
+	app.ViperConfMap = nil
+	if err := app.InitViperConfig(); err != nil {
+		t.Fatalf("apptest: %v", err)
+	}
+	if err := app.InitBaseConfig(util.GetConfigPath("base")); err != nil {
+		t.Fatalf("apptest: %v", err)
+	}
+	location, err := time.LoadLocation(app.BaseConf.Base.TimeLocation)
+	if err != nil {
+		t.Fatalf("apptest: %v", err)
+	}
+	app.TimeLocation = location
+
+	if len(o.redis) > 0 {
+		if err := app.InitRedisConfig(util.GetConfigPath("redis")); err != nil {
+			t.Fatalf("apptest: %v", err)
+		}
+		t.Cleanup(func() { _ = app.CloseRedisDB() })
+	}
+	for _, name := range o.pgsql {
+		a.openSQLite("postgres", name, o.logSQL, app.SetPgSQLPool)
+	}
+	for _, name := range o.mysql {
+		a.openSQLite("mysql", name, o.logSQL, app.SetMySQLPool)
+	}
+	return a
+}
+
+// PgSQL 获取 Postgres 命名连接池
+func (a *App) PgSQL(name string) *gorm.DB {
+	a.t.Helper()
+	db, err := app.GetPgSQLPool(name)
+	if err != nil {
+		a.t.Fatalf("apptest: %v", err)
+	}
+	return db
+}
+
+// MySQL 获取 MySQL 命名连接池
+func (a *App) MySQL(name string) *gorm.DB {
+	a.t.Helper()
+	db, err := app.GetMySQLPool(name)
+	if err != nil {
+		a.t.Fatalf("apptest: %v", err)
+	}
+	return db
+}
+
+// RedisConn 获取 Redis 命名连接池的连接，测试结束时自动关闭
+func (a *App) RedisConn(name string) redis.Conn {
+	a.t.Helper()
+	pool, err := app.GetRedisPool(name)
+	if err != nil {
+		a.t.Fatalf("apptest: %v", err)
+	}
+	conn := pool.Get()
+	if err := conn.Err(); err != nil {
+		a.t.Fatalf("apptest: %v", err)
+	}
+	a.t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// saveGlobals 保存 app 包全局变量，测试结束时恢复
+func (a *App) saveGlobals() {
+	configPath, env := util.ConfigPath, util.Env
+	viperConfMap := app.ViperConfMap
+	baseConf := app.BaseConf
+	timeLocation := app.TimeLocation
+	configRedisMap, redisPool := app.ConfigRedisMap, app.RedisPool
+	snowFlake := util.GetSnowFlake() // InitBaseConfig 按 snowflake 配置替换
+	a.t.Cleanup(func() {
+		util.ConfigPath, util.Env = configPath, env
+		app.ViperConfMap = viperConfMap
+		app.BaseConf = baseConf
+		app.TimeLocation = timeLocation
+		app.ConfigRedisMap, app.RedisPool = configRedisMap, redisPool
+		util.SetSnowFlake(snowFlake)
+	})
+}
+
+// writeConfig 写入配置文件
+func (a *App) writeConfig(files map[string]string) error {
+	if err := os.MkdirAll(a.Dir, 0o755); err != nil {
+		return err
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(a.Dir, name+".toml"), []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openSQLite 在临时目录创建 SQLite 数据库并登记为命名连接池
+func (a *App) openSQLite(driver, name string, logSQL bool, set func(string, *gorm.DB) error) {
+	a.t.Helper()
+	file := filepath.Join(filepath.Dir(a.Dir), driver+"_"+name+".db") // 不放在配置目录，避免被当作配置文件加载
+	level := gormLogger.Warn
+	if logSQL {
+		level = gormLogger.Info
+	}
+	db, err := gorm.Open(sqlite.Open(file+"?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=1"), &gorm.Config{
+		Logger: app.NewGormLogger(logger.Log, app.GormLoggerConfig{Pool: name, LogLevel: level}),
+	})
+	if err != nil {
+		a.t.Fatalf("apptest: open sqlite %s: %v", file, err)
+	}
+	if err := set(name, db); err != nil {
+		a.t.Fatalf("apptest: register %s pool %s: %v", driver, name, err)
+	}
+	a.t.Cleanup(func() {
+		_ = set(name, nil)
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+}
+
+// redisConfig 生成指向进程内 Redis 服务的连接池配置
+func redisConfig(names []string, addr string) string {
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "[list.%s]\nproxy_list = %q\nmax_idle = 4\nmax_active = 16\nread_timeout = 5\nwrite_timeout = 5\n\n", name, addr)
+	}
+	return b.String()
+}
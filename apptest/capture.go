@@ -0,0 +1,69 @@
+package apptest
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+)
+
+// LogCapture 记录日志输出的 logger.Writer
+type LogCapture struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// Init 实现 logger.Writer
+func (c *LogCapture) Init() error {
+	return nil
+}
+
+// Write 实现 logger.Writer，记录会被复用，需要立即格式化
+func (c *LogCapture) Write(record *logger.Record) error {
+	line := record.String()
+	c.mu.Lock()
+	c.lines = append(c.lines, strings.TrimSuffix(line, "\n"))
+	c.mu.Unlock()
+	return nil
+}
+
+// Lines 已记录的日志
+func (c *LogCapture) Lines() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lines := make([]string, len(c.lines))
+	copy(lines, c.lines)
+	return lines
+}
+
+// Contains 是否有包含 substr 的日志
+func (c *LogCapture) Contains(substr string) bool {
+	for _, line := range c.Lines() {
+		if strings.Contains(line, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wait 等待包含 substr 的日志，日志异步写入，断言前应使用 Wait 而非 Contains
+func (c *LogCapture) Wait(substr string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if c.Contains(substr) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Reset 清空已记录的日志
+func (c *LogCapture) Reset() {
+	c.mu.Lock()
+	c.lines = nil
+	c.mu.Unlock()
+}
@@ -0,0 +1,206 @@
+package apptest
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+)
+
+// RedisCluster 进程内 Redis 集群，多个 RedisServer 按哈希槽分担键，支持 CLUSTER SLOTS 与 MOVED / ASK 重定向；
+// 集群只使用 0 号数据库
+type RedisCluster struct {
+	Nodes []*RedisServer // 节点，创建时按序号平均分配哈希槽
+
+	mu        sync.Mutex
+	owners    [app.RedisSlots]int // 槽所在节点的序号
+	migrating map[int]int         // 迁移中的槽与目标节点的序号
+}
+
+// NewRedisCluster 启动 n 个节点的集群
+func NewRedisCluster(n int) (*RedisCluster, error) {
+	if n <= 0 {
+		return nil, errors.New("apptest: redis cluster requires at least one node")
+	}
+	c := &RedisCluster{migrating: map[int]int{}}
+	for i := 0; i < n; i++ {
+		node, err := NewRedisServer()
+		if err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+		node.cluster, node.node = c, i
+		c.Nodes = append(c.Nodes, node)
+	}
+	for slot := range c.owners {
+		c.owners[slot] = slot * n / app.RedisSlots
+	}
+	return c, nil
+}
+
+// Addrs 所有节点的地址，可用作 cluster_nodes
+func (c *RedisCluster) Addrs() []string {
+	addrs := make([]string, len(c.Nodes))
+	for i, node := range c.Nodes {
+		addrs[i] = node.Addr()
+	}
+	return addrs
+}
+
+// Close 关闭所有节点
+func (c *RedisCluster) Close() error {
+	var errs []string
+	for _, node := range c.Nodes {
+		if err := node.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// NodeOf 当前负责键所在槽的节点
+func (c *RedisCluster) NodeOf(key string) *RedisServer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Nodes[c.owners[app.RedisSlot(key)]]
+}
+
+// MigrateSlot 开始将槽迁移到 to：源节点上不存在的键回复 ASK，to 只接受 ASKING 之后的命令
+func (c *RedisCluster) MigrateSlot(slot int, to *RedisServer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.migrating[slot] = to.node
+}
+
+// MoveSlot 将槽及其中的键移动到 to 并结束迁移，之后原节点回复 MOVED
+func (c *RedisCluster) MoveSlot(slot int, to *RedisServer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.migrating, slot)
+	from := c.Nodes[c.owners[slot]]
+	c.owners[slot] = to.node
+	if from == to {
+		return
+	}
+	first, second := from, to
+	if first.node > second.node {
+		first, second = second, first
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	second.mu.Lock()
+	defer second.mu.Unlock()
+	for key, e := range from.keyspace(0) {
+		if app.RedisSlot(key) == slot {
+			to.keyspace(0)[key] = e
+			delete(from.keyspace(0), key)
+		}
+	}
+}
+
+// redirect 检查命令的键是否由 s 负责，需要重定向时返回 MOVED / ASK 错误
+func (c *RedisCluster) redirect(s *RedisServer, db int, name string, args []string, asking bool) redisReply {
+	keys := commandKeys(name, args)
+	if len(keys) == 0 {
+		return nil
+	}
+	slot := app.RedisSlot(keys[0])
+	for _, key := range keys[1:] {
+		if app.RedisSlot(key) != slot {
+			return errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	owner := c.owners[slot]
+	target, migrating := c.migrating[slot]
+	switch {
+	case owner == s.node && migrating:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, key := range keys {
+			if e, _ := s.lookup(db, key, ""); e == nil {
+				return fmt.Errorf("ASK %d %s", slot, c.Nodes[target].Addr())
+			}
+		}
+		return nil
+	case owner == s.node, migrating && target == s.node && asking:
+		return nil
+	}
+	return fmt.Errorf("MOVED %d %s", slot, c.Nodes[owner].Addr())
+}
+
+// slots CLUSTER SLOTS 的回复
+func (c *RedisCluster) slots() redisReply {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ranges []redisReply
+	for start := 0; start < app.RedisSlots; {
+		end := start
+		for end+1 < app.RedisSlots && c.owners[end+1] == c.owners[start] {
+			end++
+		}
+		host, port, _ := net.SplitHostPort(c.Nodes[c.owners[start]].Addr())
+		p, _ := strconv.Atoi(port)
+		node := []redisReply{host, int64(p), fmt.Sprintf("node%d", c.owners[start])}
+		ranges = append(ranges, []redisReply{int64(start), int64(end), node})
+		start = end + 1
+	}
+	return ranges
+}
+
+// clusterCommand CLUSTER SLOTS / KEYSLOT
+func (s *RedisServer) clusterCommand(args []string) redisReply {
+	if s.cluster == nil {
+		return errors.New("ERR This instance has cluster support disabled")
+	}
+	if len(args) == 0 {
+		return wrongArgs("CLUSTER")
+	}
+	switch strings.ToUpper(args[0]) {
+	case "SLOTS":
+		return s.cluster.slots()
+	case "KEYSLOT":
+		if len(args) != 2 {
+			return wrongArgs("CLUSTER|KEYSLOT")
+		}
+		return int64(app.RedisSlot(args[1]))
+	}
+	return fmt.Errorf("ERR unknown subcommand '%s'", args[0])
+}
+
+// commandKeys 命令中的键，没有键的命令返回 nil；自定义命令视第一个参数为键
+func commandKeys(name string, args []string) []string {
+	switch name {
+	case "PING", "ECHO", "AUTH", "FLUSHDB", "FLUSHALL", "DBSIZE", "KEYS", "SCRIPT", "INFO":
+		return nil
+	case "EVAL", "EVALSHA":
+		if len(args) < 2 {
+			return nil
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || n > len(args)-2 {
+			return nil
+		}
+		return args[2 : 2+n]
+	case "MGET", "DEL", "UNLINK", "EXISTS", "TOUCH":
+		return args
+	case "MSET":
+		var keys []string
+		for i := 0; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		return keys
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return args[:1]
+}
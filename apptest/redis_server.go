@@ -0,0 +1,1094 @@
+package apptest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 数据类型
+const (
+	typeString = "string"
+	typeHash   = "hash"
+	typeList   = "list"
+	typeSet    = "set"
+)
+
+var (
+	errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInt    = errors.New("ERR value is not an integer or out of range")
+	errSyntax    = errors.New("ERR syntax error")
+)
+
+// redisEntry 键值
+type redisEntry struct {
+	kind     string
+	str      string
+	hash     map[string]string
+	list     []string
+	set      map[string]struct{}
+	expireAt time.Time
+}
+
+// redisReply 命令返回值
+type redisReply = interface{}
+
+// redisStatus 状态回复 如 +OK
+type redisStatus string
+
+// RedisCommandFunc 自定义命令处理函数，返回值可以是 nil / string / []byte / int / int64 / error / []interface{}
+type RedisCommandFunc func(s *RedisServer, db int, args []string) interface{}
+
+// RedisScriptFunc 脚本的 Go 实现，keys 与 args 对应 Lua 中的 KEYS 与 ARGV，返回值同 RedisCommandFunc
+type RedisScriptFunc func(s *RedisServer, db int, keys, args []string) interface{}
+
+// RedisServer 进程内 Redis 协议服务，支持常用的字符串、哈希、列表、集合与过期命令，
+// 以及发布订阅与通过 Script 注册的脚本
+type RedisServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	dbs      map[int]map[string]*redisEntry
+	commands map[string]RedisCommandFunc
+	scripts  map[string]RedisScriptFunc         // 按 SHA1 注册的脚本
+	loaded   map[string]bool                    // 已通过 EVAL 或 SCRIPT LOAD 加载的脚本，EVALSHA 仅能执行已加载的
+	subs     map[string]map[*redisConn]struct{} // 频道的订阅连接
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+	now      func() time.Time
+
+	cluster *RedisCluster // 所属集群，单机模式为 nil
+	node    int           // 在集群中的序号
+}
+
+// redisConn 客户端连接状态
+type redisConn struct {
+	mu       sync.Mutex // 保护 w，发布的消息与命令回复可能并发写入
+	w        *bufio.Writer
+	db       int
+	asking   bool                // 上一条命令为 ASKING
+	channels map[string]struct{} // 已订阅的频道
+}
+
+// NewRedisServer 在 127.0.0.1 的随机端口启动服务
+func NewRedisServer() (*RedisServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &RedisServer{
+		listener: listener,
+		dbs:      map[int]map[string]*redisEntry{},
+		commands: map[string]RedisCommandFunc{},
+		scripts:  map[string]RedisScriptFunc{},
+		loaded:   map[string]bool{},
+		subs:     map[string]map[*redisConn]struct{}{},
+		conns:    map[net.Conn]struct{}{},
+		now:      time.Now,
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr 服务地址
+func (s *RedisServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close 关闭服务及所有连接
+func (s *RedisServer) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// FlushAll 清空所有数据库
+func (s *RedisServer) FlushAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dbs = map[int]map[string]*redisEntry{}
+}
+
+// Handle 注册或覆盖命令，命令名不区分大小写；处理函数执行时服务已加锁，可调用 Get / Set 等无锁方法
+func (s *RedisServer) Handle(command string, fn RedisCommandFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands[strings.ToUpper(command)] = fn
+}
+
+// Script 按 SHA1 注册脚本的 Go 实现，如 redis.Script 的 Hash()；处理函数执行时服务已加锁
+func (s *RedisServer) Script(sha string, fn RedisScriptFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[strings.ToLower(sha)] = fn
+}
+
+// Call 执行命令，仅在 Handle 或 Script 注册的处理函数中调用
+func (s *RedisServer) Call(db int, command string, args ...string) interface{} {
+	return s.call(db, strings.ToUpper(command), args)
+}
+
+// Get 读取字符串键，仅在 Handle 注册的处理函数中调用
+func (s *RedisServer) Get(db int, key string) (string, bool) {
+	e, err := s.lookup(db, key, typeString)
+	if err != nil || e == nil {
+		return "", false
+	}
+	return e.str, true
+}
+
+// Set 写入字符串键，ttl 为 0 时不过期，仅在 Handle 注册的处理函数中调用
+func (s *RedisServer) Set(db int, key, value string, ttl time.Duration) {
+	e := &redisEntry{kind: typeString, str: value}
+	if ttl > 0 {
+		e.expireAt = s.now().Add(ttl)
+	}
+	s.keyspace(db)[key] = e
+}
+
+// Del 删除键，仅在 Handle 注册的处理函数中调用
+func (s *RedisServer) Del(db int, key string) bool {
+	if e, _ := s.lookup(db, key, ""); e == nil {
+		return false
+	}
+	delete(s.keyspace(db), key)
+	return true
+}
+
+// TTL 键剩余存活时间，不存在或未设置过期返回 false
+func (s *RedisServer) TTL(db int, key string) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, _ := s.lookup(db, key, "")
+	if e == nil || e.expireAt.IsZero() {
+		return 0, false
+	}
+	return e.expireAt.Sub(s.now()), true
+}
+
+// Keys 数据库中未过期的键
+func (s *RedisServer) Keys(db int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys(db, "*")
+}
+
+func (s *RedisServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+func (s *RedisServer) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	c := &redisConn{w: bufio.NewWriter(conn), channels: map[string]struct{}{}}
+	defer s.unsubscribe(c, nil)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		name := strings.ToUpper(args[0])
+		asking := c.asking
+		c.asking = false
+		var replies []redisReply
+		switch {
+		case name == "QUIT":
+			c.write(true, redisStatus("OK"))
+			return
+		case name == "SUBSCRIBE":
+			if len(args) < 2 {
+				replies = append(replies, wrongArgs(name))
+			}
+			for _, channel := range args[1:] {
+				replies = append(replies, []redisReply{"subscribe", channel, s.subscribe(c, channel)})
+			}
+		case name == "UNSUBSCRIBE":
+			replies = s.unsubscribe(c, args[1:])
+		case len(c.channels) > 0 && name == "PING":
+			replies = append(replies, []redisReply{"pong", strings.Join(args[1:], "")})
+		case len(c.channels) > 0:
+			replies = append(replies, fmt.Errorf("ERR Can't execute '%s': only SUBSCRIBE / UNSUBSCRIBE / PING / QUIT are allowed in this context", strings.ToLower(name)))
+		case name == "SELECT":
+			if len(args) != 2 {
+				replies = append(replies, wrongArgs(name))
+			} else if n, err := strconv.Atoi(args[1]); err != nil || n < 0 {
+				replies = append(replies, errors.New("ERR DB index is out of range"))
+			} else {
+				c.db = n
+				replies = append(replies, redisStatus("OK"))
+			}
+		case name == "PUBLISH":
+			if len(args) != 3 {
+				replies = append(replies, wrongArgs(name))
+			} else {
+				replies = append(replies, s.publish(args[1], args[2]))
+			}
+		case name == "ASKING":
+			c.asking = true
+			replies = append(replies, redisStatus("OK"))
+		case name == "CLUSTER":
+			replies = append(replies, s.clusterCommand(args[1:]))
+		default:
+			replies = append(replies, s.exec(c.db, name, args[1:], asking))
+		}
+		if err := c.write(r.Buffered() == 0, replies...); err != nil {
+			return
+		}
+	}
+}
+
+// write 写入回复，flush 为 true 时发送
+func (c *redisConn) write(flush bool, replies ...redisReply) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, reply := range replies {
+		writeReply(c.w, reply)
+	}
+	if !flush {
+		return nil
+	}
+	return c.w.Flush()
+}
+
+// subscribe 订阅频道，返回连接已订阅的频道数
+func (s *RedisServer) subscribe(c *redisConn, channel string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs[channel] == nil {
+		s.subs[channel] = map[*redisConn]struct{}{}
+	}
+	s.subs[channel][c] = struct{}{}
+	c.channels[channel] = struct{}{}
+	return int64(len(c.channels))
+}
+
+// unsubscribe 取消订阅，channels 为空时取消全部
+func (s *RedisServer) unsubscribe(c *redisConn, channels []string) []redisReply {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(channels) == 0 {
+		for channel := range c.channels {
+			channels = append(channels, channel)
+		}
+		sort.Strings(channels)
+	}
+	if len(channels) == 0 {
+		return []redisReply{[]redisReply{"unsubscribe", nil, int64(0)}}
+	}
+	replies := make([]redisReply, 0, len(channels))
+	for _, channel := range channels {
+		delete(c.channels, channel)
+		if subs := s.subs[channel]; subs != nil {
+			delete(subs, c)
+			if len(subs) == 0 {
+				delete(s.subs, channel)
+			}
+		}
+		replies = append(replies, []redisReply{"unsubscribe", channel, int64(len(c.channels))})
+	}
+	return replies
+}
+
+// publish 发布消息，集群模式下发送到所有节点的订阅者，返回接收的连接数
+func (s *RedisServer) publish(channel, message string) redisReply {
+	nodes := []*RedisServer{s}
+	if s.cluster != nil {
+		nodes = s.cluster.Nodes
+	}
+	var receivers []*redisConn
+	for _, node := range nodes {
+		node.mu.Lock()
+		for c := range node.subs[channel] {
+			receivers = append(receivers, c)
+		}
+		node.mu.Unlock()
+	}
+	for _, c := range receivers {
+		_ = c.write(true, []redisReply{"message", channel, message})
+	}
+	return int64(len(receivers))
+}
+
+// exec 执行命令，集群模式下键不属于本节点时返回重定向
+func (s *RedisServer) exec(db int, name string, args []string, asking bool) redisReply {
+	if s.cluster != nil {
+		if reply := s.cluster.redirect(s, db, name, args, asking); reply != nil {
+			return reply
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.call(db, name, args)
+}
+
+// call 执行命令，调用方需持有 s.mu
+func (s *RedisServer) call(db int, name string, args []string) redisReply {
+	if fn, ok := s.commands[name]; ok {
+		return fn(s, db, args)
+	}
+	cmd, ok := redisCommands[name]
+	if !ok {
+		return fmt.Errorf("ERR unknown command '%s'", strings.ToLower(name))
+	}
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		return wrongArgs(name)
+	}
+	return cmd.fn(s, db, args)
+}
+
+// keyspace 数据库键空间
+func (s *RedisServer) keyspace(db int) map[string]*redisEntry {
+	keys, ok := s.dbs[db]
+	if !ok {
+		keys = map[string]*redisEntry{}
+		s.dbs[db] = keys
+	}
+	return keys
+}
+
+// lookup 查找未过期的键，kind 不为空时校验类型
+func (s *RedisServer) lookup(db int, key, kind string) (*redisEntry, error) {
+	keys := s.keyspace(db)
+	e, ok := keys[key]
+	if !ok {
+		return nil, nil
+	}
+	if !e.expireAt.IsZero() && !s.now().Before(e.expireAt) {
+		delete(keys, key)
+		return nil, nil
+	}
+	if kind != "" && e.kind != kind {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// create 查找键，不存在时创建
+func (s *RedisServer) create(db int, key, kind string) (*redisEntry, error) {
+	e, err := s.lookup(db, key, kind)
+	if err != nil || e != nil {
+		return e, err
+	}
+	e = &redisEntry{kind: kind}
+	switch kind {
+	case typeHash:
+		e.hash = map[string]string{}
+	case typeSet:
+		e.set = map[string]struct{}{}
+	}
+	s.keyspace(db)[key] = e
+	return e, nil
+}
+
+// keys 匹配的未过期键
+func (s *RedisServer) keys(db int, pattern string) []string {
+	var list []string
+	for key := range s.keyspace(db) {
+		if e, _ := s.lookup(db, key, ""); e == nil {
+			continue
+		}
+		if ok, _ := path.Match(pattern, key); ok {
+			list = append(list, key)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+type redisCommand struct {
+	minArgs, maxArgs int // maxArgs 为 -1 时不限制
+	fn               RedisCommandFunc
+}
+
+// redisCommands 内置命令
+var redisCommands = map[string]redisCommand{
+	"PING":    {0, 1, cmdPing},
+	"ECHO":    {1, 1, func(s *RedisServer, db int, args []string) redisReply { return args[0] }},
+	"AUTH":    {1, 2, func(s *RedisServer, db int, args []string) redisReply { return redisStatus("OK") }},
+	"FLUSHDB": {0, 1, func(s *RedisServer, db int, args []string) redisReply { delete(s.dbs, db); return redisStatus("OK") }},
+	"FLUSHALL": {0, 1, func(s *RedisServer, db int, args []string) redisReply {
+		s.dbs = map[int]map[string]*redisEntry{}
+		return redisStatus("OK")
+	}},
+	"DBSIZE": {0, 0, func(s *RedisServer, db int, args []string) redisReply { return int64(len(s.keys(db, "*"))) }},
+	"KEYS":   {1, 1, func(s *RedisServer, db int, args []string) redisReply { return stringsReply(s.keys(db, args[0])) }},
+	"GET":    {1, 1, cmdGet},
+	"SET":    {2, -1, cmdSet},
+	"SETNX": {2, 2, func(s *RedisServer, db int, args []string) redisReply {
+		return setReply(s, db, args[0], args[1], 0, true, false)
+	}},
+	"SETEX":     {3, 3, cmdSetEx(time.Second)},
+	"PSETEX":    {3, 3, cmdSetEx(time.Millisecond)},
+	"GETSET":    {2, 2, cmdGetSet},
+	"MGET":      {1, -1, cmdMGet},
+	"MSET":      {2, -1, cmdMSet},
+	"INCR":      {1, 1, func(s *RedisServer, db int, args []string) redisReply { return incrBy(s, db, args[0], "1") }},
+	"DECR":      {1, 1, func(s *RedisServer, db int, args []string) redisReply { return incrBy(s, db, args[0], "-1") }},
+	"INCRBY":    {2, 2, func(s *RedisServer, db int, args []string) redisReply { return incrBy(s, db, args[0], args[1]) }},
+	"DECRBY":    {2, 2, cmdDecrBy},
+	"DEL":       {1, -1, cmdDel},
+	"UNLINK":    {1, -1, cmdDel},
+	"EXISTS":    {1, -1, cmdExists},
+	"TYPE":      {1, 1, cmdType},
+	"EXPIRE":    {2, 2, cmdExpire(time.Second)},
+	"PEXPIRE":   {2, 2, cmdExpire(time.Millisecond)},
+	"PERSIST":   {1, 1, cmdPersist},
+	"TTL":       {1, 1, cmdTTL(time.Second)},
+	"PTTL":      {1, 1, cmdTTL(time.Millisecond)},
+	"HSET":      {3, -1, cmdHSet},
+	"HGET":      {2, 2, cmdHGet},
+	"HDEL":      {2, -1, cmdHDel},
+	"HGETALL":   {1, 1, cmdHGetAll},
+	"HLEN":      {1, 1, cmdHLen},
+	"HINCRBY":   {3, 3, cmdHIncrBy},
+	"LPUSH":     {2, -1, cmdPush(true)},
+	"RPUSH":     {2, -1, cmdPush(false)},
+	"LPOP":      {1, 1, cmdPop(true)},
+	"RPOP":      {1, 1, cmdPop(false)},
+	"LLEN":      {1, 1, cmdLLen},
+	"LRANGE":    {3, 3, cmdLRange},
+	"SADD":      {2, -1, cmdSAdd},
+	"SREM":      {2, -1, cmdSRem},
+	"SMEMBERS":  {1, 1, cmdSMembers},
+	"SISMEMBER": {2, 2, cmdSIsMember},
+	"SCARD":     {1, 1, cmdSCard},
+	"EVAL":      {2, -1, cmdEval(false)},
+	"EVALSHA":   {2, -1, cmdEval(true)},
+	"SCRIPT":    {1, -1, cmdScript},
+}
+
+func cmdPing(s *RedisServer, db int, args []string) redisReply {
+	if len(args) == 1 {
+		return args[0]
+	}
+	return redisStatus("PONG")
+}
+
+func cmdGet(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeString)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return nil
+	}
+	return e.str
+}
+
+// cmdSet SET key value [EX seconds | PX milliseconds] [NX | XX]
+func cmdSet(s *RedisServer, db int, args []string) redisReply {
+	var ttl time.Duration
+	nx, xx := false, false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if i+1 >= len(args) {
+				return errSyntax
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return errors.New("ERR invalid expire time in 'set' command")
+			}
+			unit := time.Second
+			if strings.ToUpper(args[i]) == "PX" {
+				unit = time.Millisecond
+			}
+			ttl = time.Duration(n) * unit
+			i++
+		default:
+			return errSyntax
+		}
+	}
+	if nx && xx {
+		return errSyntax
+	}
+	reply := setReply(s, db, args[0], args[1], ttl, nx, xx)
+	if reply == int64(0) {
+		return nil
+	}
+	return redisStatus("OK")
+}
+
+// setReply 写入字符串，nx / xx 条件不满足时返回 0
+func setReply(s *RedisServer, db int, key, value string, ttl time.Duration, nx, xx bool) redisReply {
+	e, _ := s.lookup(db, key, "")
+	if (nx && e != nil) || (xx && e == nil) {
+		return int64(0)
+	}
+	s.Set(db, key, value, ttl)
+	return int64(1)
+}
+
+func cmdSetEx(unit time.Duration) func(s *RedisServer, db int, args []string) redisReply {
+	return func(s *RedisServer, db int, args []string) redisReply {
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || n <= 0 {
+			return errors.New("ERR invalid expire time")
+		}
+		s.Set(db, args[0], args[2], time.Duration(n)*unit)
+		return redisStatus("OK")
+	}
+}
+
+func cmdGetSet(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeString)
+	if err != nil {
+		return err
+	}
+	s.Set(db, args[0], args[1], 0)
+	if e == nil {
+		return nil
+	}
+	return e.str
+}
+
+func cmdMGet(s *RedisServer, db int, args []string) redisReply {
+	list := make([]redisReply, len(args))
+	for i, key := range args {
+		if e, err := s.lookup(db, key, typeString); err == nil && e != nil {
+			list[i] = e.str
+		}
+	}
+	return list
+}
+
+func cmdMSet(s *RedisServer, db int, args []string) redisReply {
+	if len(args)%2 != 0 {
+		return wrongArgs("MSET")
+	}
+	for i := 0; i < len(args); i += 2 {
+		s.Set(db, args[i], args[i+1], 0)
+	}
+	return redisStatus("OK")
+}
+
+// incrBy 整数自增，保留原有过期时间
+func incrBy(s *RedisServer, db int, key, delta string) redisReply {
+	d, err := strconv.ParseInt(delta, 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+	e, err := s.create(db, key, typeString)
+	if err != nil {
+		return err
+	}
+	n := int64(0)
+	if e.str != "" {
+		if n, err = strconv.ParseInt(e.str, 10, 64); err != nil {
+			return errNotInt
+		}
+	}
+	n += d
+	e.str = strconv.FormatInt(n, 10)
+	return n
+}
+
+func cmdDecrBy(s *RedisServer, db int, args []string) redisReply {
+	d, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+	return incrBy(s, db, args[0], strconv.FormatInt(-d, 10))
+}
+
+func cmdDel(s *RedisServer, db int, args []string) redisReply {
+	n := int64(0)
+	for _, key := range args {
+		if s.Del(db, key) {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdExists(s *RedisServer, db int, args []string) redisReply {
+	n := int64(0)
+	for _, key := range args {
+		if e, _ := s.lookup(db, key, ""); e != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func cmdType(s *RedisServer, db int, args []string) redisReply {
+	e, _ := s.lookup(db, args[0], "")
+	if e == nil {
+		return redisStatus("none")
+	}
+	return redisStatus(e.kind)
+}
+
+func cmdExpire(unit time.Duration) func(s *RedisServer, db int, args []string) redisReply {
+	return func(s *RedisServer, db int, args []string) redisReply {
+		n, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return errNotInt
+		}
+		e, _ := s.lookup(db, args[0], "")
+		if e == nil {
+			return int64(0)
+		}
+		if n <= 0 {
+			s.Del(db, args[0])
+			return int64(1)
+		}
+		e.expireAt = s.now().Add(time.Duration(n) * unit)
+		return int64(1)
+	}
+}
+
+func cmdPersist(s *RedisServer, db int, args []string) redisReply {
+	e, _ := s.lookup(db, args[0], "")
+	if e == nil || e.expireAt.IsZero() {
+		return int64(0)
+	}
+	e.expireAt = time.Time{}
+	return int64(1)
+}
+
+func cmdTTL(unit time.Duration) func(s *RedisServer, db int, args []string) redisReply {
+	return func(s *RedisServer, db int, args []string) redisReply {
+		e, _ := s.lookup(db, args[0], "")
+		if e == nil {
+			return int64(-2)
+		}
+		if e.expireAt.IsZero() {
+			return int64(-1)
+		}
+		remain := e.expireAt.Sub(s.now())
+		return int64((remain + unit - 1) / unit)
+	}
+}
+
+func cmdHSet(s *RedisServer, db int, args []string) redisReply {
+	if len(args)%2 != 1 {
+		return wrongArgs("HSET")
+	}
+	e, err := s.create(db, args[0], typeHash)
+	if err != nil {
+		return err
+	}
+	n := int64(0)
+	for i := 1; i < len(args); i += 2 {
+		if _, ok := e.hash[args[i]]; !ok {
+			n++
+		}
+		e.hash[args[i]] = args[i+1]
+	}
+	return n
+}
+
+func cmdHGet(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeHash)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		return nil
+	}
+	if v, ok := e.hash[args[1]]; ok {
+		return v
+	}
+	return nil
+}
+
+func cmdHDel(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeHash)
+	if err != nil || e == nil {
+		return replyOr(err, int64(0))
+	}
+	n := int64(0)
+	for _, field := range args[1:] {
+		if _, ok := e.hash[field]; ok {
+			delete(e.hash, field)
+			n++
+		}
+	}
+	if len(e.hash) == 0 {
+		s.Del(db, args[0])
+	}
+	return n
+}
+
+func cmdHGetAll(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeHash)
+	if err != nil || e == nil {
+		return replyOr(err, []redisReply{})
+	}
+	fields := make([]string, 0, len(e.hash))
+	for field := range e.hash {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	list := make([]redisReply, 0, len(fields)*2)
+	for _, field := range fields {
+		list = append(list, field, e.hash[field])
+	}
+	return list
+}
+
+func cmdHLen(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeHash)
+	if err != nil || e == nil {
+		return replyOr(err, int64(0))
+	}
+	return int64(len(e.hash))
+}
+
+func cmdHIncrBy(s *RedisServer, db int, args []string) redisReply {
+	d, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return errNotInt
+	}
+	e, err := s.create(db, args[0], typeHash)
+	if err != nil {
+		return err
+	}
+	n := int64(0)
+	if v, ok := e.hash[args[1]]; ok {
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return errNotInt
+		}
+	}
+	n += d
+	e.hash[args[1]] = strconv.FormatInt(n, 10)
+	return n
+}
+
+func cmdPush(left bool) func(s *RedisServer, db int, args []string) redisReply {
+	return func(s *RedisServer, db int, args []string) redisReply {
+		e, err := s.create(db, args[0], typeList)
+		if err != nil {
+			return err
+		}
+		for _, v := range args[1:] {
+			if left {
+				e.list = append([]string{v}, e.list...)
+			} else {
+				e.list = append(e.list, v)
+			}
+		}
+		return int64(len(e.list))
+	}
+}
+
+func cmdPop(left bool) func(s *RedisServer, db int, args []string) redisReply {
+	return func(s *RedisServer, db int, args []string) redisReply {
+		e, err := s.lookup(db, args[0], typeList)
+		if err != nil || e == nil || len(e.list) == 0 {
+			return replyOr(err, nil)
+		}
+		var v string
+		if left {
+			v, e.list = e.list[0], e.list[1:]
+		} else {
+			v, e.list = e.list[len(e.list)-1], e.list[:len(e.list)-1]
+		}
+		if len(e.list) == 0 {
+			s.Del(db, args[0])
+		}
+		return v
+	}
+}
+
+func cmdLLen(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeList)
+	if err != nil || e == nil {
+		return replyOr(err, int64(0))
+	}
+	return int64(len(e.list))
+}
+
+func cmdLRange(s *RedisServer, db int, args []string) redisReply {
+	start, err1 := strconv.Atoi(args[1])
+	stop, err2 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil {
+		return errNotInt
+	}
+	e, err := s.lookup(db, args[0], typeList)
+	if err != nil || e == nil {
+		return replyOr(err, []redisReply{})
+	}
+	n := len(e.list)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	list := []redisReply{}
+	for i := start; i <= stop; i++ {
+		list = append(list, e.list[i])
+	}
+	return list
+}
+
+func cmdSAdd(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.create(db, args[0], typeSet)
+	if err != nil {
+		return err
+	}
+	n := int64(0)
+	for _, member := range args[1:] {
+		if _, ok := e.set[member]; !ok {
+			e.set[member] = struct{}{}
+			n++
+		}
+	}
+	return n
+}
+
+func cmdSRem(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeSet)
+	if err != nil || e == nil {
+		return replyOr(err, int64(0))
+	}
+	n := int64(0)
+	for _, member := range args[1:] {
+		if _, ok := e.set[member]; ok {
+			delete(e.set, member)
+			n++
+		}
+	}
+	if len(e.set) == 0 {
+		s.Del(db, args[0])
+	}
+	return n
+}
+
+func cmdSMembers(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeSet)
+	if err != nil || e == nil {
+		return replyOr(err, []redisReply{})
+	}
+	members := make([]string, 0, len(e.set))
+	for member := range e.set {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return stringsReply(members)
+}
+
+func cmdSIsMember(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeSet)
+	if err != nil || e == nil {
+		return replyOr(err, int64(0))
+	}
+	if _, ok := e.set[args[1]]; ok {
+		return int64(1)
+	}
+	return int64(0)
+}
+
+func cmdSCard(s *RedisServer, db int, args []string) redisReply {
+	e, err := s.lookup(db, args[0], typeSet)
+	if err != nil || e == nil {
+		return replyOr(err, int64(0))
+	}
+	return int64(len(e.set))
+}
+
+// cmdEval EVAL script numkeys [key ...] [arg ...]，执行 Script 注册的 Go 实现
+func cmdEval(sha bool) func(s *RedisServer, db int, args []string) redisReply {
+	return func(s *RedisServer, db int, args []string) redisReply {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return errNotInt
+		}
+		if n < 0 || n > len(args)-2 {
+			return errors.New("ERR Number of keys can't be greater than number of args")
+		}
+		id := strings.ToLower(args[0])
+		if !sha {
+			id = scriptSHA(args[0])
+		} else if !s.loaded[id] {
+			return errors.New("NOSCRIPT No matching script. Please use EVAL.")
+		}
+		fn, ok := s.scripts[id]
+		if !ok {
+			return errors.New("ERR apptest: script not registered, use RedisServer.Script")
+		}
+		s.loaded[id] = true
+		return fn(s, db, args[2:2+n], args[2+n:])
+	}
+}
+
+// cmdScript SCRIPT LOAD / EXISTS / FLUSH
+func cmdScript(s *RedisServer, db int, args []string) redisReply {
+	switch strings.ToUpper(args[0]) {
+	case "LOAD":
+		if len(args) != 2 {
+			return wrongArgs("SCRIPT|LOAD")
+		}
+		id := scriptSHA(args[1])
+		if _, ok := s.scripts[id]; !ok {
+			return errors.New("ERR apptest: script not registered, use RedisServer.Script")
+		}
+		s.loaded[id] = true
+		return id
+	case "EXISTS":
+		list := make([]redisReply, len(args)-1)
+		for i, id := range args[1:] {
+			list[i] = s.loaded[strings.ToLower(id)]
+		}
+		return list
+	case "FLUSH":
+		s.loaded = map[string]bool{}
+		return redisStatus("OK")
+	}
+	return fmt.Errorf("ERR unknown subcommand '%s'", args[0])
+}
+
+// scriptSHA 脚本的 SHA1
+func scriptSHA(src string) string {
+	sum := sha1.Sum([]byte(src))
+	return hex.EncodeToString(sum[:])
+}
+
+// replyOr err 不为空时返回 err，否则返回 reply
+func replyOr(err error, reply redisReply) redisReply {
+	if err != nil {
+		return err
+	}
+	return reply
+}
+
+func stringsReply(list []string) []redisReply {
+	replies := make([]redisReply, len(list))
+	for i, v := range list {
+		replies[i] = v
+	}
+	return replies
+}
+
+func wrongArgs(name string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(name))
+}
+
+// readCommand 读取 RESP 数组或内联命令
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, errors.New("invalid multibulk length")
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errors.New("expected bulk string")
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, errors.New("invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// writeReply 按 RESP 格式写入回复
+func writeReply(w *bufio.Writer, reply redisReply) {
+	switch v := reply.(type) {
+	case nil:
+		_, _ = w.WriteString("$-1\r\n")
+	case redisStatus:
+		_, _ = fmt.Fprintf(w, "+%s\r\n", v)
+	case error:
+		msg := v.Error()
+		if prefix := strings.SplitN(msg, " ", 2)[0]; prefix != strings.ToUpper(prefix) {
+			msg = "ERR " + msg // 没有错误类型前缀
+		}
+		_, _ = fmt.Fprintf(w, "-%s\r\n", strings.ReplaceAll(msg, "\r\n", " "))
+	case int:
+		_, _ = fmt.Fprintf(w, ":%d\r\n", v)
+	case int64:
+		_, _ = fmt.Fprintf(w, ":%d\r\n", v)
+	case bool:
+		if v {
+			_, _ = w.WriteString(":1\r\n")
+		} else {
+			_, _ = w.WriteString(":0\r\n")
+		}
+	case string:
+		_, _ = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []byte:
+		_, _ = fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []redisReply:
+		_, _ = fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	case []string:
+		writeReply(w, stringsReply(v))
+	default:
+		writeReply(w, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"gorm.io/gorm"
+)
+
+// 操作类型
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// DLTagAudit LogWriter 日志标签
+const DLTagAudit = "_com_audit"
+
+// Auditable 需要审计的模型，返回不记录的字段名 (如密码)
+type Auditable interface {
+	AuditIgnore() []string
+}
+
+// Record 审计记录
+type Record struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Table     string    `gorm:"column:table_name;size:128;not null;index:idx_audit_records_target,priority:1" json:"table"`
+	RecordID  string    `gorm:"size:255;not null;index:idx_audit_records_target,priority:2" json:"record_id"`
+	Action    string    `gorm:"size:16;not null" json:"action"`
+	Actor     string    `gorm:"size:255;index" json:"actor"`
+	TraceID   string    `gorm:"size:64" json:"trace_id"`
+	Before    string    `gorm:"type:text" json:"before"`  // 变更前的 JSON
+	After     string    `gorm:"type:text" json:"after"`   // 变更后的 JSON
+	Changes   string    `gorm:"type:text" json:"changes"` // 变更字段 {"field": {"old": x, "new": y}}
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 审计表名
+func (Record) TableName() string {
+	return "audit_records"
+}
+
+// Change 字段变更
+type Change struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// ChangeMap 解析变更字段
+func (r *Record) ChangeMap() (map[string]Change, error) {
+	changes := map[string]Change{}
+	if r.Changes == "" {
+		return changes, nil
+	}
+	err := json.Unmarshal([]byte(r.Changes), &changes)
+	return changes, err
+}
+
+// AutoMigrate 创建审计表
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Record{})
+}
+
+type actorContextKey struct{}
+
+// WithActor 在上下文中设置操作人
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+// ActorFromContext 获取上下文中的操作人
+func ActorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(actorContextKey{}).(string)
+	return actor
+}
+
+// Writer 审计记录写入目标
+type Writer interface {
+	Write(db *gorm.DB, records []*Record) error
+}
+
+// TableWriter 写入审计表，与被审计的变更处于同一事务
+type TableWriter struct{}
+
+// Write 实现 Writer
+func (TableWriter) Write(db *gorm.DB, records []*Record) error {
+	return db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Create(records).Error
+}
+
+// LogWriter 写入日志
+type LogWriter struct{}
+
+// Write 实现 Writer
+func (LogWriter) Write(db *gorm.DB, records []*Record) error {
+	for _, r := range records {
+		trace := &logger.TContext{}
+		trace.TraceID = r.TraceID
+		logger.Log.TagInfo(trace, DLTagAudit, map[string]interface{}{
+			"table":     r.Table,
+			"record_id": r.RecordID,
+			"action":    r.Action,
+			"actor":     r.Actor,
+			"changes":   r.Changes,
+		})
+	}
+	return nil
+}
+
+// History 查询记录的审计历史，按时间先后排序；model 为模型指针，id 为主键，复合主键用逗号连接
+func History(db *gorm.DB, model interface{}, id interface{}) ([]Record, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	var records []Record
+	err := db.Where("table_name = ? AND record_id = ?", stmt.Schema.Table, fmt.Sprint(id)).Order("id").Find(&records).Error
+	return records, err
+}
@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/MetaverseTopDJ/Scaffold/apptest"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+)
+
+type account struct {
+	ID       uint `gorm:"primaryKey"`
+	Name     string
+	Password string
+}
+
+func (account) AuditIgnore() []string {
+	return []string{"Password"}
+}
+
+// setupDB 注册审计插件并建表
+func setupDB(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	if err := db.Use(New(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&account{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// change 读取记录中字段的变更
+func change(t *testing.T, r Record, field string) (Change, bool) {
+	t.Helper()
+	changes, err := r.ChangeMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := changes[field]
+	return c, ok
+}
+
+func TestAuditHistory(t *testing.T) {
+	db := apptest.New(t, apptest.WithMySQL("default")).MySQL("default")
+	setupDB(t, db)
+	ctx := WithActor(context.Background(), "alice")
+
+	acc := &account{Name: "a", Password: "secret"}
+	if err := db.WithContext(ctx).Create(acc).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(ctx).Model(acc).Update("name", "b").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.WithContext(ctx).Model(acc).Update("name", "b").Error; err != nil { // 没有变化，不记录
+		t.Fatal(err)
+	}
+	if err := db.WithContext(ctx).Model(acc).Update("password", "changed").Error; err != nil { // 只改忽略的字段，不记录
+		t.Fatal(err)
+	}
+	if err := db.WithContext(ctx).Delete(acc).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := History(db, &account{}, acc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("history has %d record(s), want 3: %+v", len(records), records)
+	}
+	for i, action := range []string{ActionCreate, ActionUpdate, ActionDelete} {
+		r := records[i]
+		if r.Action != action || r.Actor != "alice" || r.Table != "accounts" || r.RecordID != "1" {
+			t.Fatalf("record %d = %+v", i, r)
+		}
+		if strings.Contains(r.Before+r.After+r.Changes, "secret") || strings.Contains(r.Before+r.After+r.Changes, "changed") {
+			t.Fatalf("record %d contains ignored field: %+v", i, r)
+		}
+	}
+	if c, ok := change(t, records[0], "name"); !ok || c.Old != nil || c.New != "a" || records[0].Before != "" {
+		t.Fatalf("create change = %+v, before %q", c, records[0].Before)
+	}
+	if c, ok := change(t, records[1], "name"); !ok || c.Old != "a" || c.New != "b" {
+		t.Fatalf("update change = %+v", c)
+	}
+	if _, ok := change(t, records[1], "id"); ok {
+		t.Fatal("unchanged field recorded in update")
+	}
+	if c, ok := change(t, records[2], "name"); !ok || c.Old != "b" || c.New != nil || records[2].After != "" {
+		t.Fatalf("delete change = %+v, after %q", c, records[2].After)
+	}
+
+	other, err := History(db, &account{}, acc.ID+1)
+	if err != nil || len(other) != 0 {
+		t.Fatalf("history of other record = %v, %v", other, err)
+	}
+}
+
+func TestAuditReadsPrimary(t *testing.T) {
+	dir := t.TempDir()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "primary.db")), &gorm.Config{Logger: logger.Discard, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	setupDB(t, db)
+	// 副本落后于主库：记录仍是旧值
+	replica, err := gorm.Open(sqlite.Open(filepath.Join(dir, "replica.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.AutoMigrate(&account{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := replica.Create(&account{ID: 1, Name: "stale"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := replica.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+	if err := db.Use(dbresolver.Register(dbresolver.Config{Replicas: []gorm.Dialector{sqlite.Open(filepath.Join(dir, "replica.db"))}})); err != nil {
+		t.Fatal(err)
+	}
+
+	acc := &account{ID: 1, Name: "a"}
+	if err := db.Create(acc).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(acc).Update("name", "b").Error; err != nil {
+		t.Fatal(err)
+	}
+	records, err := History(db.Clauses(dbresolver.Write), &account{}, acc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("history has %d record(s), want 2", len(records))
+	}
+	if c, ok := change(t, records[1], "name"); !ok || c.Old != "a" || c.New != "b" {
+		t.Fatalf("update change = %+v, want a -> b", c)
+	}
+}
@@ -0,0 +1,330 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+const beforeKey = "scaffold:audit_before" // 变更前的记录
+
+// Plugin 审计 gorm 插件，仅记录实现 Auditable 的模型
+type Plugin struct {
+	writer Writer
+}
+
+// New 创建审计插件，writer 为空时写入审计表
+func New(writer Writer) *Plugin {
+	if writer == nil {
+		writer = TableWriter{}
+	}
+	return &Plugin{writer: writer}
+}
+
+// Register 为所有 MySQL / Postgres 命名连接池注册审计插件
+func Register(writer Writer) error {
+	return app.RegisterSQLPlugin(func(driver, name string) gorm.Plugin {
+		return New(writer)
+	})
+}
+
+// Name 插件名称
+func (p *Plugin) Name() string {
+	return "scaffold:audit"
+}
+
+// Initialize 注册回调
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().After("gorm:create").Register("scaffold:audit_after_create", p.afterCreate),
+		cb.Update().Before("gorm:update").Register("scaffold:audit_before_update", p.before),
+		cb.Update().After("gorm:update").Register("scaffold:audit_after_update", p.after(ActionUpdate)),
+		cb.Delete().Before("gorm:delete").Register("scaffold:audit_before_delete", p.before),
+		cb.Delete().After("gorm:delete").Register("scaffold:audit_after_delete", p.after(ActionDelete)),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshot 记录主键与字段值
+type snapshot struct {
+	id     string
+	values map[string]interface{}
+}
+
+// auditable 模型是否需要审计，返回忽略的字段
+func auditable(db *gorm.DB) (map[string]bool, bool) {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.ModelType == nil {
+		return nil, false
+	}
+	model, ok := reflect.New(db.Statement.Schema.ModelType).Interface().(Auditable)
+	if !ok {
+		return nil, false
+	}
+	ignore := map[string]bool{}
+	for _, name := range model.AuditIgnore() {
+		if field := db.Statement.Schema.LookUpField(name); field != nil {
+			ignore[field.DBName] = true
+		}
+	}
+	return ignore, true
+}
+
+func (p *Plugin) afterCreate(db *gorm.DB) {
+	ignore, ok := auditable(db)
+	if !ok {
+		return
+	}
+	var records []*Record
+	for _, value := range reflectValues(db.Statement.ReflectValue) {
+		after := takeSnapshot(db, value, ignore)
+		records = append(records, p.record(db, ActionCreate, after.id, nil, after.values))
+	}
+	p.write(db, records)
+}
+
+// before 更新、删除前读取受影响的记录
+func (p *Plugin) before(db *gorm.DB) {
+	if _, ok := auditable(db); !ok {
+		return
+	}
+	rows, err := p.load(db, db.Statement.Clauses["WHERE"], primaryKeys(db))
+	if err != nil {
+		_ = db.AddError(fmt.Errorf("audit: load before state: %w", err))
+		return
+	}
+	db.InstanceSet(beforeKey, rows)
+}
+
+func (p *Plugin) after(action string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ignore, ok := auditable(db)
+		if !ok {
+			return
+		}
+		value, ok := db.InstanceGet(beforeKey)
+		if !ok {
+			return
+		}
+		before, _ := value.([]reflect.Value)
+		if len(before) == 0 {
+			return
+		}
+		afterByID := map[string]snapshot{}
+		if action == ActionUpdate {
+			ids := make([][]interface{}, 0, len(before))
+			for _, row := range before {
+				ids = append(ids, primaryValues(db, row))
+			}
+			rows, err := p.load(db, clause.Clause{}, ids)
+			if err != nil {
+				_ = db.AddError(fmt.Errorf("audit: load after state: %w", err))
+				return
+			}
+			for _, row := range rows {
+				s := takeSnapshot(db, row, ignore)
+				afterByID[s.id] = s
+			}
+		}
+		var records []*Record
+		for _, row := range before {
+			old := takeSnapshot(db, row, ignore)
+			var values map[string]interface{}
+			if after, ok := afterByID[old.id]; ok {
+				values = after.values
+			}
+			r := p.record(db, action, old.id, old.values, values)
+			if action == ActionUpdate && r.Changes == "{}" {
+				continue // 没有变化
+			}
+			records = append(records, r)
+		}
+		p.write(db, records)
+	}
+}
+
+// load 按条件或主键读取模型记录，与当前语句使用同一连接 (事务)，不在事务中时读取主库，避免副本延迟
+func (p *Plugin) load(db *gorm.DB, where clause.Clause, ids [][]interface{}) ([]reflect.Value, error) {
+	s := db.Statement.Schema
+	query := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(db.Statement.Table).Clauses(dbresolver.Write)
+	if db.Statement.Unscoped {
+		query = query.Unscoped()
+	}
+	conditions := 0
+	if w, ok := where.Expression.(clause.Where); ok && len(w.Exprs) > 0 {
+		query = query.Clauses(w)
+		conditions++
+	}
+	if len(ids) > 0 && len(s.PrimaryFields) > 0 {
+		columns := make([]clause.Column, len(s.PrimaryFields))
+		for i, field := range s.PrimaryFields {
+			columns[i] = clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+		}
+		values := make([]interface{}, len(ids))
+		for i, id := range ids {
+			if len(columns) == 1 {
+				values[i] = id[0]
+			} else {
+				values[i] = id
+			}
+		}
+		if len(columns) == 1 {
+			query = query.Where(clause.IN{Column: columns[0], Values: values})
+		} else {
+			query = query.Where(clause.Expr{SQL: "? IN ?", Vars: []interface{}{columns, values}})
+		}
+		conditions++
+	}
+	if conditions == 0 {
+		return nil, nil // 全表操作不审计
+	}
+	rows := reflect.New(reflect.SliceOf(s.ModelType))
+	if err := query.Find(rows.Interface()).Error; err != nil {
+		return nil, err
+	}
+	list := make([]reflect.Value, rows.Elem().Len())
+	for i := range list {
+		list[i] = rows.Elem().Index(i)
+	}
+	return list, nil
+}
+
+// primaryKeys 语句目标对象中非零的主键值
+func primaryKeys(db *gorm.DB) [][]interface{} {
+	var ids [][]interface{}
+	for _, value := range reflectValues(db.Statement.ReflectValue) {
+		id := primaryValues(db, value)
+		zero := len(id) == 0
+		for _, field := range db.Statement.Schema.PrimaryFields {
+			if _, isZero := field.ValueOf(db.Statement.Context, value); isZero {
+				zero = true
+			}
+		}
+		if !zero {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// primaryValues 记录的主键值
+func primaryValues(db *gorm.DB, value reflect.Value) []interface{} {
+	values := make([]interface{}, 0, len(db.Statement.Schema.PrimaryFields))
+	for _, field := range db.Statement.Schema.PrimaryFields {
+		v, _ := field.ValueOf(db.Statement.Context, value)
+		values = append(values, v)
+	}
+	return values
+}
+
+// reflectValues 展开结构体或切片
+func reflectValues(value reflect.Value) []reflect.Value {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{value}
+	case reflect.Slice, reflect.Array:
+		list := make([]reflect.Value, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			list = append(list, reflectValues(value.Index(i))...)
+		}
+		return list
+	}
+	return nil
+}
+
+// takeSnapshot 读取记录的字段值
+func takeSnapshot(db *gorm.DB, value reflect.Value, ignore map[string]bool) snapshot {
+	s := snapshot{values: map[string]interface{}{}}
+	for _, field := range db.Statement.Schema.Fields {
+		if field.DBName == "" || ignore[field.DBName] {
+			continue
+		}
+		v, _ := field.ValueOf(db.Statement.Context, value)
+		s.values[field.DBName] = v
+	}
+	ids := primaryValues(db, value)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprint(id)
+	}
+	s.id = strings.Join(parts, ",")
+	return s
+}
+
+// record 生成审计记录
+func (p *Plugin) record(db *gorm.DB, action, id string, before, after map[string]interface{}) *Record {
+	ctx := db.Statement.Context
+	r := &Record{
+		Table:     db.Statement.Table,
+		RecordID:  id,
+		Action:    action,
+		Actor:     ActorFromContext(ctx),
+		TraceID:   logger.TraceIDFromContext(ctx),
+		Before:    marshal(before),
+		After:     marshal(after),
+		CreatedAt: time.Now(),
+	}
+	changes := map[string]Change{}
+	for name, v := range after {
+		if old := before[name]; !equal(old, v) {
+			changes[name] = Change{Old: old, New: v}
+		}
+	}
+	if after == nil { // 删除
+		for name, old := range before {
+			changes[name] = Change{Old: old}
+		}
+	}
+	r.Changes = marshal(changes)
+	return r
+}
+
+// write 写入审计记录，失败时使语句报错以回滚事务
+func (p *Plugin) write(db *gorm.DB, records []*Record) {
+	if len(records) == 0 || db.Error != nil {
+		return
+	}
+	if err := p.writer.Write(db, records); err != nil {
+		_ = db.AddError(fmt.Errorf("audit: write records: %w", err))
+	}
+}
+
+// equal 按 JSON 表示比较字段值
+func equal(a, b interface{}) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+func marshal(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if m, ok := v.(map[string]interface{}); ok && m == nil {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
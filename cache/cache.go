@@ -0,0 +1,391 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"github.com/gomodule/redigo/redis"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+)
+
+// 默认缓存参数
+const (
+	defaultJitter        = 0.1
+	defaultNegativeTTL   = time.Minute
+	defaultLocalTTL      = time.Minute
+	defaultLoadTimeout   = time.Second * 10
+	defaultChannel       = "cache:invalidate"
+	subscribePing        = time.Second * 30
+	subscribeRetryWait   = time.Second
+	subscribeReadTimeout = subscribePing * 2
+)
+
+// 缓存值的标记字节
+const (
+	markValue    = 'v'
+	markNotFound = 'n'
+)
+
+var (
+	ErrNotFound     = errors.New("cache: not found")                                            // 缓存未命中，或 loader 返回了未找到
+	ErrLocalCluster = errors.New("cache: local tier is not supported on redis cluster clients") // 集群模式无法订阅失效通知
+)
+
+// Loader 缓存未命中时加载数据，返回 ErrNotFound 或 gorm.ErrRecordNotFound 时缓存为未找到
+type Loader func(ctx context.Context) (interface{}, error)
+
+// Options 缓存参数
+type Options struct {
+	Codec       Codec         // 编解码，默认 JSON
+	Jitter      float64       // TTL 随机增加的最大比例，默认 0.1，小于 0 时不增加
+	NegativeTTL time.Duration // 未找到的缓存时间，默认 1m，小于 0 时不缓存
+	LocalSize   int           // 进程内 LRU 容量，0 表示不启用；失效通知依赖订阅，集群模式下返回 ErrLocalCluster
+	LocalTTL    time.Duration // 进程内缓存时间，默认 1m，不超过 Redis 中的 TTL
+	Channel     string        // 进程内缓存失效通知的频道，默认 cache:invalidate
+	LoadTimeout time.Duration // 合并后的加载 (读取 Redis、loader、回写) 的超时时间，默认 10s
+}
+
+// Cache 基于 Redis 命名连接池的旁路缓存，可选进程内 LRU 一级缓存
+type Cache struct {
+	client  *app.RedisClient
+	options Options
+	group   singleflight.Group
+	local   *localCache
+
+	mu   sync.Mutex
+	psc  *redis.PubSubConn // 订阅连接，Send / Flush 需持有 mu
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New 创建缓存，启用进程内缓存时订阅失效通知，需调用 Close 停止
+func New(client *app.RedisClient, options Options) (*Cache, error) {
+	if options.LocalSize > 0 && client.Cluster() != nil {
+		return nil, ErrLocalCluster
+	}
+	if options.Codec == nil {
+		options.Codec = JSON
+	}
+	if options.Jitter == 0 {
+		options.Jitter = defaultJitter
+	}
+	if options.NegativeTTL == 0 {
+		options.NegativeTTL = defaultNegativeTTL
+	}
+	if options.LocalTTL <= 0 {
+		options.LocalTTL = defaultLocalTTL
+	}
+	if options.Channel == "" {
+		options.Channel = defaultChannel
+	}
+	if options.LoadTimeout <= 0 {
+		options.LoadTimeout = defaultLoadTimeout
+	}
+	c := &Cache{client: client, options: options}
+	if options.LocalSize > 0 {
+		c.local = newLocalCache(options.LocalSize)
+		c.stop, c.done = make(chan struct{}), make(chan struct{})
+		go c.subscribe()
+	}
+	return c, nil
+}
+
+// NewNamed 使用 Redis 命名连接池创建缓存
+func NewNamed(name string, options Options) (*Cache, error) {
+	client, err := app.GetRedisClient(name)
+	if err != nil {
+		return nil, err
+	}
+	return New(client, options)
+}
+
+// Get 读取缓存到 dest，未命中或缓存为未找到时返回 ErrNotFound
+func (c *Cache) Get(ctx context.Context, key string, dest interface{}) error {
+	data, err := c.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return c.decode(data, dest)
+}
+
+// Set 写入缓存，ttl 为 0 时不过期
+func (c *Cache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := c.encode(value)
+	if err != nil {
+		return err
+	}
+	if err := c.client.Set(ctx, key, data, c.jitter(ttl)); err != nil {
+		return err
+	}
+	c.invalidate(ctx, key)
+	return nil
+}
+
+// Delete 删除缓存并通知所有实例清除进程内缓存
+func (c *Cache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if _, err := c.client.Del(ctx, keys...); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		c.invalidate(ctx, key)
+	}
+	return nil
+}
+
+// GetOrLoad 读取缓存到 dest，未命中时调用 loader 并写入缓存；同一个键的并发加载只执行一次，
+// 加载不随发起者的 ctx 取消，每个调用方在各自的 ctx 取消时停止等待
+func (c *Cache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader Loader, dest interface{}) error {
+	if c.local != nil {
+		if data, ok := c.local.get(key); ok {
+			return c.decode(data, dest)
+		}
+	}
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(detach(ctx), c.options.LoadTimeout)
+		defer cancel()
+		return c.load(loadCtx, key, ttl, loader)
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case result := <-ch:
+		if result.Err != nil {
+			return result.Err
+		}
+		return c.decode(result.Val.([]byte), dest)
+	}
+}
+
+// detachedContext 保留上下文中的值 (如链路追踪)，但不继承取消与截止时间
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// detach 脱离 ctx 的取消
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+// load 从 Redis 读取，未命中时调用 loader 并回写，Redis 不可用时直接返回 loader 的结果
+func (c *Cache) load(ctx context.Context, key string, ttl time.Duration, loader Loader) ([]byte, error) {
+	data, err := c.get(ctx, key)
+	if err == nil || (err == ErrNotFound && data != nil) {
+		return data, err
+	}
+	if err != ErrNotFound {
+		logger.Warn("cache get %s failed: %v", key, err)
+	}
+	value, err := loader(ctx)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+		if c.options.NegativeTTL > 0 {
+			c.store(ctx, key, []byte{markNotFound}, c.options.NegativeTTL)
+		}
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data, err = c.encode(value); err != nil {
+		return nil, err
+	}
+	c.store(ctx, key, data, ttl)
+	return data, nil
+}
+
+// store 回写 Redis 与进程内缓存，失败只记录日志
+func (c *Cache) store(ctx context.Context, key string, data []byte, ttl time.Duration) {
+	if err := c.client.Set(ctx, key, data, c.jitter(ttl)); err != nil {
+		logger.Warn("cache set %s failed: %v", key, err)
+		return
+	}
+	c.setLocal(key, data, ttl)
+}
+
+// get 读取编码后的值，缓存为未找到时返回标记与 ErrNotFound
+func (c *Cache) get(ctx context.Context, key string) ([]byte, error) {
+	if c.local != nil {
+		if data, ok := c.local.get(key); ok {
+			return checkMark(data)
+		}
+	}
+	data, err := c.client.GetBytes(ctx, key)
+	if err == redis.ErrNil {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err = checkMark(data)
+	if c.local != nil && data != nil {
+		ttl, _ := c.client.TTL(ctx, key) // 进程内缓存不晚于 Redis 过期
+		c.setLocal(key, data, ttl)
+	}
+	return data, err
+}
+
+// checkMark 检查标记字节
+func checkMark(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != markValue && data[0] != markNotFound {
+		return nil, errors.New("cache: malformed value")
+	}
+	if data[0] == markNotFound {
+		return data, ErrNotFound
+	}
+	return data, nil
+}
+
+// setLocal 写入进程内缓存，ttl 小于 0 (不过期) 或大于 LocalTTL 时使用 LocalTTL
+func (c *Cache) setLocal(key string, data []byte, ttl time.Duration) {
+	if c.local == nil {
+		return
+	}
+	if ttl <= 0 || ttl > c.options.LocalTTL {
+		ttl = c.options.LocalTTL
+	}
+	c.local.set(key, data, ttl)
+}
+
+// encode 加上标记字节后编码
+func (c *Cache) encode(value interface{}) ([]byte, error) {
+	encoded, err := c.options.Codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{markValue}, encoded...), nil
+}
+
+// decode 去掉标记字节后解码，缓存为未找到时返回 ErrNotFound
+func (c *Cache) decode(data []byte, dest interface{}) error {
+	data, err := checkMark(data)
+	if err != nil {
+		return err
+	}
+	return c.options.Codec.Unmarshal(data[1:], dest)
+}
+
+// jitter TTL 随机增加 [0, Jitter) 比例，避免同时过期
+func (c *Cache) jitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 || c.options.Jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Float64()*c.options.Jitter*float64(ttl))
+}
+
+// invalidate 清除本实例的进程内缓存并通知其他实例
+func (c *Cache) invalidate(ctx context.Context, key string) {
+	if c.local == nil {
+		return
+	}
+	c.local.remove(key)
+	if _, err := c.client.Publish(ctx, c.options.Channel, key); err != nil {
+		logger.Warn("cache publish invalidation %s failed: %v", key, err)
+	}
+}
+
+// subscribe 订阅失效通知，断开后重连并清空进程内缓存
+func (c *Cache) subscribe() {
+	defer close(c.done)
+	for {
+		if err := c.receive(); err != nil {
+			logger.Warn("cache invalidation subscription failed: %v", err)
+		}
+		c.local.clear()
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(subscribeRetryWait):
+		}
+	}
+}
+
+// receive 订阅并处理通知直到连接断开或停止
+func (c *Cache) receive() error {
+	conn, err := c.client.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	psc := &redis.PubSubConn{Conn: conn}
+	c.mu.Lock()
+	select {
+	case <-c.stop:
+		c.mu.Unlock()
+		return nil
+	default:
+	}
+	err = psc.Subscribe(c.client.Key(c.options.Channel))
+	c.psc = psc
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.psc = nil
+		c.mu.Unlock()
+	}()
+	if err != nil {
+		return err
+	}
+
+	pingDone := make(chan struct{})
+	defer close(pingDone)
+	go func() {
+		ticker := time.NewTicker(subscribePing)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pingDone:
+				return
+			case <-ticker.C:
+				c.mu.Lock()
+				_ = psc.Ping("")
+				c.mu.Unlock()
+			}
+		}
+	}()
+	for {
+		switch v := psc.ReceiveWithTimeout(subscribeReadTimeout).(type) {
+		case redis.Message:
+			c.local.remove(string(v.Data))
+		case redis.Subscription:
+			if v.Count == 0 { // Close 取消订阅
+				return nil
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+// Close 停止订阅失效通知
+func (c *Cache) Close() error {
+	if c.local == nil {
+		return nil
+	}
+	c.mu.Lock()
+	select {
+	case <-c.stop:
+		c.mu.Unlock()
+		return nil
+	default:
+	}
+	close(c.stop)
+	if c.psc != nil {
+		_ = c.psc.Unsubscribe() // 结束阻塞的 Receive
+	}
+	c.mu.Unlock()
+	<-c.done
+	return nil
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/apptest"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// initRedis 使用临时配置文件初始化 Redis 命名连接池
+func initRedis(t *testing.T, toml string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "redis.toml")
+	if err := os.WriteFile(path, []byte(toml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.InitRedisConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = app.CloseRedisDB() })
+}
+
+func TestLocalTierRejectedOnCluster(t *testing.T) {
+	initRedis(t, `[list.cluster]
+cluster_nodes = ["127.0.0.1:1"]
+`)
+	if _, err := NewNamed("cluster", Options{LocalSize: 10}); err != ErrLocalCluster {
+		t.Fatalf("got %v, want ErrLocalCluster", err)
+	}
+	c, err := NewNamed("cluster", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+}
+
+// newTestCache 使用进程内 Redis 服务创建缓存
+func newTestCache(t *testing.T, options Options) (*Cache, *apptest.RedisServer) {
+	t.Helper()
+	srv, err := apptest.NewRedisServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+	initRedis(t, `[list.default]
+proxy_list = "`+srv.Addr()+`"
+prefix = "test:"
+`)
+	c, err := NewNamed("default", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c, srv
+}
+
+func TestGetOrLoadSurvivesFirstCallerCancel(t *testing.T) {
+	c, _ := newTestCache(t, Options{})
+	var calls int32
+	release := make(chan struct{})
+	started := make(chan struct{})
+	loader := func(ctx context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		select {
+		case <-release:
+			return "value", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		var v string
+		firstErr <- c.GetOrLoad(first, "k", time.Minute, loader, &v)
+	}()
+	<-started
+	secondErr := make(chan error, 1)
+	var second string
+	go func() {
+		secondErr <- c.GetOrLoad(context.Background(), "k", time.Minute, loader, &second)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Fatalf("first caller got %v, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-secondErr; err != nil {
+		t.Fatal(err)
+	}
+	if second != "value" {
+		t.Fatalf("got %q", second)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("loader called %d times", n)
+	}
+}
+
+func TestLocalTierInvalidatedAcrossInstances(t *testing.T) {
+	a, srv := newTestCache(t, Options{LocalSize: 10})
+	b, err := NewNamed("default", Options{LocalSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	conn, err := redis.Dial("tcp", srv.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) { // 等待两个实例订阅完成
+		if n, _ := redis.Int(conn.Do("PUBLISH", "test:"+defaultChannel, "none")); n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscriptions not established")
+		}
+	}
+
+	ctx := context.Background()
+	if err := a.Set(ctx, "k", "v1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var v string
+	if err := b.Get(ctx, "k", &v); err != nil || v != "v1" {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+	if err := a.Set(ctx, "k", "v2", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); v != "v2"; time.Sleep(10 * time.Millisecond) {
+		if err := b.Get(ctx, "k", &v); err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("local tier still returns %q after invalidation", v)
+		}
+	}
+}
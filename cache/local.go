@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// localEntry 进程内缓存项，保存编码后的值
+type localEntry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+// localCache 带过期时间的进程内 LRU 缓存
+type localCache struct {
+	size int
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List // 最近使用的排在最前
+}
+
+func newLocalCache(size int) *localCache {
+	return &localCache{size: size, items: map[string]*list.Element{}, order: list.New()}
+}
+
+// get 获取未过期的值
+func (l *localCache) get(key string) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*localEntry)
+	if time.Now().After(entry.expiresAt) {
+		l.order.Remove(elem)
+		delete(l.items, key)
+		return nil, false
+	}
+	l.order.MoveToFront(elem)
+	return entry.data, true
+}
+
+// set 写入值，超过容量时淘汰最久未使用的
+func (l *localCache) set(key string, data []byte, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	expiresAt := time.Now().Add(ttl)
+	if elem, ok := l.items[key]; ok {
+		entry := elem.Value.(*localEntry)
+		entry.data, entry.expiresAt = data, expiresAt
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.order.PushFront(&localEntry{key: key, data: data, expiresAt: expiresAt})
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*localEntry).key)
+	}
+}
+
+// remove 删除值
+func (l *localCache) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.items[key]; ok {
+		l.order.Remove(elem)
+		delete(l.items, key)
+	}
+}
+
+// clear 清空，订阅断开期间可能错过失效通知时使用
+func (l *localCache) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.items = map[string]*list.Element{}
+	l.order.Init()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/migrate"
+	"github.com/MetaverseTopDJ/Scaffold/util"
+
+	"gorm.io/gorm"
+)
+
+// 数据库迁移命令
+// go run ./cmd/migrate -config ./conf/dev/ -driver postgres -pool default -dir ./migrations up
+// 子命令: up / down / status / redo
+func main() {
+	driver := flag.String("driver", "postgres", "database module: postgres or mysql")
+	pool := flag.String("pool", "default", "named database pool")
+	dir := flag.String("dir", "./migrations", "migrations directory")
+	table := flag.String("table", "schema_migrations", "migrations version table")
+	steps := flag.Int("steps", 0, "number of migrations to apply (up) or roll back (down)")
+
+	// 命令行参数在 InitModule 中解析，因此同时加载两种数据库模块
+	if err := app.InitModule("./conf/dev/", []string{"base", "log", "mysql", "postgres"}); err != nil {
+		log.Fatal(err)
+	}
+	defer app.Destroy()
+
+	db, err := getPool(*driver, *pool)
+	if err != nil {
+		log.Fatal(err)
+	}
+	migrator := migrate.New(db).SetTable(*table)
+	if err := migrator.LoadDir(*dir); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	switch command := flag.Arg(0); command {
+	case "up":
+		err = migrator.Up(ctx, *steps)
+	case "down":
+		err = migrator.Down(ctx, *steps)
+	case "redo":
+		err = migrator.Redo(ctx)
+	case "status", "":
+		err = printStatus(ctx, migrator)
+	default:
+		err = fmt.Errorf("unknown command %q, expected up / down / status / redo", command)
+	}
+	if err != nil {
+		log.Println(err)
+		app.Destroy()
+		os.Exit(1)
+	}
+}
+
+// getPool 获取命名连接池
+func getPool(driver, name string) (*gorm.DB, error) {
+	switch driver {
+	case "mysql":
+		return app.GetMySQLPool(name)
+	case "postgres":
+		return app.GetPgSQLPool(name)
+	}
+	return nil, fmt.Errorf("unsupported driver %q", driver)
+}
+
+// printStatus 打印迁移状态
+func printStatus(ctx context.Context, migrator *migrate.Migrator) error {
+	list, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, status := range list {
+		state := "pending"
+		if status.Applied {
+			state = "applied " + util.DateTime(status.AppliedAt)
+		}
+		if status.Modified {
+			state += " (modified)"
+		}
+		if status.Missing {
+			state += " (missing)"
+		}
+		fmt.Printf("%-20d %-40s %s\n", status.Version, status.Name, state)
+	}
+	return nil
+}
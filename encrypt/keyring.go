@@ -0,0 +1,183 @@
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/MetaverseTopDJ/Scaffold/util"
+)
+
+// prefix 密文前缀，格式为 enc:<key id>:<base64(nonce + 密文)>
+const prefix = "enc:"
+
+var (
+	ErrNoKeyring     = errors.New("encrypt: keyring not initialized")
+	ErrUnknownKey    = errors.New("encrypt: unknown key id")
+	ErrInvalidCipher = errors.New("encrypt: invalid ciphertext")
+)
+
+// Config 加密配置，密钥为 base64 编码的 16 / 24 / 32 字节
+type Config struct {
+	Primary       string            `mapstructure:"primary"`         // 加密使用的密钥 ID
+	Keys          map[string]string `mapstructure:"keys"`            // 密钥 ID 到密钥，旧密钥保留用于解密
+	BlindIndexKey string            `mapstructure:"blind_index_key"` // 盲索引 HMAC 密钥，为空时不支持盲索引
+}
+
+// Keyring 按密钥 ID 管理的 AES-GCM 密钥
+type Keyring struct {
+	primary string
+	aeads   map[string]cipher.AEAD
+	index   []byte
+}
+
+var (
+	keyringLock sync.RWMutex
+	keyring     *Keyring
+)
+
+// InitConfig 加载加密配置并设置默认密钥环
+func InitConfig(path string) error {
+	config := &Config{}
+	if err := util.ParseConfig(path, config); err != nil {
+		return err
+	}
+	k, err := NewKeyring(config)
+	if err != nil {
+		return err
+	}
+	SetDefault(k)
+	return nil
+}
+
+// SetDefault 设置默认密钥环，EncryptedString 与插件使用
+func SetDefault(k *Keyring) {
+	keyringLock.Lock()
+	keyring = k
+	keyringLock.Unlock()
+}
+
+// Default 获取默认密钥环
+func Default() (*Keyring, error) {
+	keyringLock.RLock()
+	defer keyringLock.RUnlock()
+	if keyring == nil {
+		return nil, ErrNoKeyring
+	}
+	return keyring, nil
+}
+
+// NewKeyring 创建密钥环
+func NewKeyring(config *Config) (*Keyring, error) {
+	k := &Keyring{primary: config.Primary, aeads: make(map[string]cipher.AEAD, len(config.Keys))}
+	for id, encoded := range config.Keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("encrypt: invalid key id %q", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt: decode key %s: %w", id, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt: key %s: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads[id] = aead
+	}
+	if _, ok := k.aeads[k.primary]; !ok {
+		return nil, fmt.Errorf("encrypt: primary key %q not in keys", k.primary)
+	}
+	if config.BlindIndexKey != "" {
+		index, err := base64.StdEncoding.DecodeString(config.BlindIndexKey)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt: decode blind index key: %w", err)
+		}
+		k.index = index
+	}
+	return k, nil
+}
+
+// Encrypt 使用主密钥加密
+func (k *Keyring) Encrypt(plaintext []byte) (string, error) {
+	aead := k.aeads[k.primary]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(k.primary))
+	return prefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 按密文中的密钥 ID 解密
+func (k *Keyring) Decrypt(ciphertext string) ([]byte, error) {
+	id, sealed, err := parseCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCipher
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCipher, err)
+	}
+	return plaintext, nil
+}
+
+// NeedsRotation 密文是否由非主密钥加密，重新保存记录即可轮换
+func (k *Keyring) NeedsRotation(ciphertext string) bool {
+	id, _, err := parseCiphertext(ciphertext)
+	return err == nil && id != k.primary
+}
+
+// BlindIndex 计算盲索引 (HMAC-SHA256 十六进制)，用于密文字段的等值查询
+func (k *Keyring) BlindIndex(value string) (string, error) {
+	if len(k.index) == 0 {
+		return "", errors.New("encrypt: blind_index_key not configured")
+	}
+	h := hmac.New(sha256.New, k.index)
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// BlindIndex 使用默认密钥环计算盲索引
+func BlindIndex(value string) (string, error) {
+	k, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return k.BlindIndex(value)
+}
+
+// parseCiphertext 解析密文的密钥 ID 与数据
+func parseCiphertext(ciphertext string) (string, []byte, error) {
+	if !strings.HasPrefix(ciphertext, prefix) {
+		return "", nil, ErrInvalidCipher
+	}
+	parts := strings.SplitN(ciphertext[len(prefix):], ":", 2)
+	if len(parts) != 2 {
+		return "", nil, ErrInvalidCipher
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, ErrInvalidCipher
+	}
+	return parts[0], sealed, nil
+}
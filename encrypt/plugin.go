@@ -0,0 +1,158 @@
+package encrypt
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// TagBlindIndex 盲索引字段标签，值为来源字段名，如 `blind_index:"Phone"`
+const TagBlindIndex = "blind_index"
+
+// Plugin 写入时根据来源字段自动填充盲索引字段
+type Plugin struct{}
+
+// Register 为所有 MySQL / Postgres 命名连接池注册盲索引插件
+func Register() error {
+	return app.RegisterSQLPlugin(func(driver, name string) gorm.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Name 插件名称
+func (p *Plugin) Name() string {
+	return "scaffold:encrypt"
+}
+
+// Initialize 注册回调
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("scaffold:blind_index_create", fillCreate); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("scaffold:blind_index_update", fillUpdate)
+}
+
+// blindIndex 盲索引字段及其来源字段
+type blindIndex struct {
+	index  *schema.Field
+	source *schema.Field
+}
+
+// blindIndexes 模型中带 blind_index 标签的字段
+func blindIndexes(db *gorm.DB) []blindIndex {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return nil
+	}
+	var list []blindIndex
+	for _, field := range db.Statement.Schema.Fields {
+		name, ok := field.Tag.Lookup(TagBlindIndex)
+		if !ok || field.DBName == "" {
+			continue
+		}
+		source := db.Statement.Schema.LookUpField(name)
+		if source == nil {
+			_ = db.AddError(fmt.Errorf("encrypt: blind index source field %s not found in %s", name, db.Statement.Schema.Name))
+			return nil
+		}
+		list = append(list, blindIndex{index: field, source: source})
+	}
+	return list
+}
+
+func fillCreate(db *gorm.DB) {
+	indexes := blindIndexes(db)
+	if len(indexes) > 0 {
+		fillStruct(db, db.Statement.ReflectValue, indexes)
+	}
+}
+
+func fillUpdate(db *gorm.DB) {
+	indexes := blindIndexes(db)
+	if len(indexes) == 0 {
+		return
+	}
+	switch dest := db.Statement.Dest.(type) {
+	case map[string]interface{}:
+		for _, bi := range indexes {
+			value, ok := dest[bi.source.DBName]
+			if !ok {
+				value, ok = dest[bi.source.Name]
+			}
+			if !ok {
+				continue
+			}
+			index, err := indexOf(value)
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			dest[bi.index.DBName] = index
+		}
+	default:
+		value := reflect.ValueOf(dest)
+		for value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+		if value.Kind() == reflect.Struct && value.Type() == db.Statement.Schema.ModelType {
+			if !value.CanAddr() { // Updates(struct) 传入的值无法修改，改为更新其副本
+				copied := reflect.New(value.Type())
+				copied.Elem().Set(value)
+				db.Statement.Dest, value = copied.Interface(), copied.Elem()
+			}
+			fillStruct(db, value, indexes) // Save 与 Updates(&struct)
+		}
+	}
+}
+
+// fillStruct 为结构体或切片中的每条记录填充盲索引，来源字段为空时不填充，结构体不可修改时返回错误
+func fillStruct(db *gorm.DB, value reflect.Value, indexes []blindIndex) {
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fillStruct(db, value.Index(i), indexes)
+		}
+	case reflect.Struct:
+		if !value.CanAddr() {
+			_ = db.AddError(fmt.Errorf("encrypt: cannot fill blind index of unaddressable %s, pass a pointer", value.Type()))
+			return
+		}
+		ctx := db.Statement.Context
+		for _, bi := range indexes {
+			source, zero := bi.source.ValueOf(ctx, value)
+			if zero {
+				continue
+			}
+			index, err := indexOf(source)
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			if err := bi.index.Set(ctx, value, index); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+	}
+}
+
+// indexOf 计算字段值的盲索引
+func indexOf(value interface{}) (string, error) {
+	for v := reflect.ValueOf(value); v.Kind() == reflect.Ptr; v = v.Elem() {
+		if v.IsNil() {
+			return "", nil
+		}
+		value = v.Elem().Interface()
+	}
+	s := fmt.Sprint(value)
+	if s == "" {
+		return "", nil
+	}
+	return BlindIndex(s)
+}
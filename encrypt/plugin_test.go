@@ -0,0 +1,90 @@
+package encrypt
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type account struct {
+	ID         uint `gorm:"primaryKey"`
+	Email      string
+	EmailIndex string `blind_index:"Email"`
+}
+
+// newTestDB 创建注册了盲索引插件的 SQLite 连接池
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	key := base64.StdEncoding.EncodeToString(make([]byte, 32))
+	k, err := NewKeyring(&Config{Primary: "k1", Keys: map[string]string{"k1": key}, BlindIndexKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetDefault(k)
+	t.Cleanup(func() { SetDefault(nil) })
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "encrypt.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(&Plugin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&account{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// expectIndex 检查记录的盲索引与 email 一致
+func expectIndex(t *testing.T, db *gorm.DB, id uint, email string) {
+	t.Helper()
+	var got account
+	if err := db.First(&got, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	want, err := BlindIndex(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != email || got.EmailIndex != want {
+		t.Fatalf("got %+v, want email %s with index %s", got, email, want)
+	}
+}
+
+func TestBlindIndexUpdates(t *testing.T) {
+	db := newTestDB(t)
+	a := &account{Email: "a@example.com"}
+	if err := db.Create(a).Error; err != nil {
+		t.Fatal(err)
+	}
+	expectIndex(t, db, a.ID, "a@example.com")
+
+	if err := db.Model(a).Updates(account{Email: "b@example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	expectIndex(t, db, a.ID, "b@example.com")
+
+	if err := db.Model(a).Updates(&account{Email: "c@example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	expectIndex(t, db, a.ID, "c@example.com")
+
+	if err := db.Model(a).Updates(map[string]interface{}{"email": "d@example.com"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	expectIndex(t, db, a.ID, "d@example.com")
+}
+
+func TestBlindIndexUnaddressableCreate(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.Create([1]account{{Email: "a@example.com"}}).Error; err == nil {
+		t.Fatal("create of unaddressable records succeeded without blind index")
+	}
+	var n int64
+	if err := db.Model(&account{}).Count(&n).Error; err != nil || n != 0 {
+		t.Fatalf("count = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,61 @@
+package encrypt
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// EncryptedString 写入时加密、读取时解密的字符串字段，空字符串不加密
+type EncryptedString string
+
+// Value 实现 driver.Valuer
+func (s EncryptedString) Value() (driver.Value, error) {
+	if s == "" {
+		return "", nil
+	}
+	k, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return k.Encrypt([]byte(s))
+}
+
+// Scan 实现 sql.Scanner
+func (s *EncryptedString) Scan(value interface{}) error {
+	var ciphertext string
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+		return nil
+	case string:
+		ciphertext = v
+	case []byte:
+		ciphertext = string(v)
+	default:
+		return fmt.Errorf("encrypt: cannot scan %T into EncryptedString", value)
+	}
+	if ciphertext == "" {
+		*s = ""
+		return nil
+	}
+	k, err := Default()
+	if err != nil {
+		return err
+	}
+	plaintext, err := k.Decrypt(ciphertext)
+	if err != nil {
+		return err
+	}
+	*s = EncryptedString(plaintext)
+	return nil
+}
+
+// GormDataType 密文长度不固定，使用 text 类型
+func (EncryptedString) GormDataType() string {
+	return "text"
+}
+
+// String 明文
+func (s EncryptedString) String() string {
+	return string(s)
+}
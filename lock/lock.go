@@ -0,0 +1,290 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	mathrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 默认锁参数
+const (
+	defaultTTL           = time.Second * 30
+	defaultRetryInterval = time.Millisecond * 100
+)
+
+var (
+	ErrNotAcquired = errors.New("lock: not acquired") // 超时仍未获取到锁
+	ErrLost        = errors.New("lock: lost")         // 续期失败或锁已被他人持有
+	ErrReleased    = errors.New("lock: released")     // 锁已释放
+)
+
+// acquireScript 键不存在时设置并递增栅栏计数，返回栅栏令牌，已被持有时返回 0
+var acquireScript = redis.NewScript(2, `
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// releaseScript 仅当值与持有者令牌一致时删除
+var releaseScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// renewScript 仅当值与持有者令牌一致时续期
+var renewScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Options 锁参数
+type Options struct {
+	TTL           time.Duration // 锁的过期时间，默认 30s
+	Timeout       time.Duration // 获取锁的最长等待时间，0 表示只尝试一次
+	RetryInterval time.Duration // 重试间隔，实际间隔在 [0.5, 1.5) 倍之间随机，默认 100ms
+	RenewInterval time.Duration // 自动续期间隔，默认 TTL/3，小于 0 时不自动续期，需调用 Refresh
+}
+
+// Locker 基于 Redis 命名连接池的分布式锁
+type Locker struct {
+	client  *app.RedisClient
+	options Options
+}
+
+// New 创建分布式锁
+func New(client *app.RedisClient, options Options) *Locker {
+	if options.TTL <= 0 {
+		options.TTL = defaultTTL
+	}
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = defaultRetryInterval
+	}
+	if options.RenewInterval == 0 {
+		options.RenewInterval = options.TTL / 3
+	}
+	return &Locker{client: client, options: options}
+}
+
+// NewNamed 使用 Redis 命名连接池创建分布式锁
+func NewNamed(name string, options Options) (*Locker, error) {
+	client, err := app.GetRedisClient(name)
+	if err != nil {
+		return nil, err
+	}
+	return New(client, options), nil
+}
+
+// Lock 已持有的锁
+type Lock struct {
+	locker *Locker
+	key    string
+	token  string
+	fence  int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{} // 续期协程退出
+
+	mu     sync.Mutex
+	err    error       // 锁失效的原因
+	expiry *time.Timer // 距上次获取或续期即将超过 TTL 时视为丢失
+}
+
+// lockKey 锁的键，使用 {} 使栅栏计数与锁位于同一个集群槽
+func lockKey(key string) string {
+	return "lock:{" + key + "}"
+}
+
+// Acquire 获取锁，在 Timeout 内按 RetryInterval 重试；返回的锁的 Context 派生自 ctx，锁丢失或释放时取消
+func (l *Locker) Acquire(ctx context.Context, key string) (*Lock, error) {
+	var deadline <-chan time.Time
+	if l.options.Timeout > 0 {
+		timer := time.NewTimer(l.options.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		lock, err := l.TryAcquire(ctx, key)
+		if err != ErrNotAcquired {
+			return lock, err
+		}
+		if deadline == nil {
+			return nil, ErrNotAcquired
+		}
+		wait := l.options.RetryInterval/2 + time.Duration(mathrand.Int63n(int64(l.options.RetryInterval)))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, ErrNotAcquired
+		case <-time.After(wait):
+		}
+	}
+}
+
+// TryAcquire 尝试获取一次锁，已被持有时返回 ErrNotAcquired
+func (l *Locker) TryAcquire(ctx context.Context, key string) (*Lock, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	name := lockKey(key)
+	start := time.Now()
+	fence, err := redis.Int64(l.client.Eval(ctx, acquireScript, []string{name, name + ":fence"}, token, l.options.TTL.Milliseconds()))
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, ErrNotAcquired
+	}
+	lock := &Lock{locker: l, key: key, token: token, fence: fence, done: make(chan struct{})}
+	lock.ctx, lock.cancel = context.WithCancel(ctx)
+	lock.mu.Lock() // expiry 可能立即到期，fail 需要等待赋值完成
+	lock.expiry = time.AfterFunc(expiryDelay(start, l.options.TTL), func() {
+		if lock.fail(ErrLost) {
+			logger.Warn("lock %s lost, not renewed within ttl", key)
+		}
+	})
+	lock.mu.Unlock()
+	if l.options.RenewInterval > 0 {
+		go lock.renewLoop()
+	} else {
+		close(lock.done)
+	}
+	return lock, nil
+}
+
+// Key 锁的名称
+func (lk *Lock) Key() string {
+	return lk.key
+}
+
+// Token 栅栏令牌，同一个锁每次获取都严格递增，写入下游存储时用于拒绝过期持有者的请求
+func (lk *Lock) Token() int64 {
+	return lk.fence
+}
+
+// Context 持有锁期间有效的上下文，锁丢失、未及时续期或释放时取消
+func (lk *Lock) Context() context.Context {
+	return lk.ctx
+}
+
+// Err 锁失效的原因，持有中返回 nil
+func (lk *Lock) Err() error {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	return lk.err
+}
+
+// Refresh 手动续期到 ttl，锁已不属于当前持有者时返回 ErrLost
+func (lk *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	if err := lk.Err(); err != nil {
+		return err
+	}
+	start := time.Now()
+	ok, err := redis.Bool(lk.locker.client.Eval(ctx, renewScript, []string{lockKey(lk.key)}, lk.token, ttl.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		lk.fail(ErrLost)
+		return ErrLost
+	}
+	lk.mu.Lock()
+	if lk.err == nil {
+		lk.expiry.Reset(expiryDelay(start, ttl))
+	}
+	lk.mu.Unlock()
+	return nil
+}
+
+// Release 释放锁，锁已过期或被他人持有时返回 ErrLost；ctx 不能使用锁自身的 Context
+func (lk *Lock) Release(ctx context.Context) error {
+	if !lk.fail(ErrReleased) {
+		switch lk.Err() {
+		case ErrReleased:
+			return nil
+		case ErrLost:
+			return ErrLost
+		} // 父上下文已取消，锁可能仍被持有，继续删除
+	}
+	<-lk.done
+	ok, err := redis.Bool(lk.locker.client.Eval(ctx, releaseScript, []string{lockKey(lk.key)}, lk.token))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLost
+	}
+	return nil
+}
+
+// fail 标记锁失效并取消上下文，返回是否为首次失效
+func (lk *Lock) fail(err error) bool {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+	if lk.err != nil {
+		return false
+	}
+	lk.err = err
+	lk.expiry.Stop()
+	lk.cancel()
+	return true
+}
+
+// expiryDelay 从 start 起到视为丢失的时间，提前 TTL 的 1/10 为时钟漂移与网络延迟留出余量
+func expiryDelay(start time.Time, ttl time.Duration) time.Duration {
+	return time.Until(start.Add(ttl - ttl/10))
+}
+
+// renewLoop 定期续期，锁已被他人持有时视为丢失；续期失败时继续重试，直到 expiry 到期
+func (lk *Lock) renewLoop() {
+	defer close(lk.done)
+	options := lk.locker.options
+	ticker := time.NewTicker(options.RenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lk.ctx.Done():
+			lk.fail(lk.ctx.Err()) // 父上下文取消时不再续期，未释放的锁随过期失效
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(lk.ctx, options.RenewInterval)
+		err := lk.Refresh(ctx, options.TTL)
+		cancel()
+		switch {
+		case err == nil:
+		case err == ErrLost || err == ErrReleased:
+			if err == ErrLost {
+				logger.Warn("lock %s lost", lk.key)
+			}
+			return
+		case lk.ctx.Err() == nil:
+			logger.Warn("lock %s renew failed: %v", lk.key, err)
+		}
+	}
+}
+
+// newToken 随机的持有者令牌
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,124 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/apptest"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// newTestLocker 使用进程内 Redis 服务创建分布式锁，脚本以 Go 实现注册
+func newTestLocker(t *testing.T, options Options) (*Locker, *apptest.RedisServer) {
+	t.Helper()
+	server, err := apptest.NewRedisServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+	server.Script(acquireScript.Hash(), func(s *apptest.RedisServer, db int, keys, args []string) interface{} {
+		if s.Call(db, "SET", keys[0], args[0], "NX", "PX", args[1]) == nil {
+			return int64(0)
+		}
+		return s.Call(db, "INCR", keys[1])
+	})
+	server.Script(releaseScript.Hash(), ownerScript("DEL"))
+	server.Script(renewScript.Hash(), ownerScript("PEXPIRE"))
+
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", server.Addr()) }}
+	t.Cleanup(func() { _ = pool.Close() })
+	return New(app.NewRedisClient(pool, "test:"), options), server
+}
+
+// ownerScript 值与令牌一致时执行 command
+func ownerScript(command string) apptest.RedisScriptFunc {
+	return func(s *apptest.RedisServer, db int, keys, args []string) interface{} {
+		if v, ok := s.Get(db, keys[0]); !ok || v != args[0] {
+			return int64(0)
+		}
+		return s.Call(db, command, append(keys[:1:1], args[1:]...)...)
+	}
+}
+
+func TestLockRenews(t *testing.T) {
+	locker, _ := newTestLocker(t, Options{TTL: time.Millisecond * 300, RenewInterval: time.Millisecond * 100})
+	lock, err := locker.TryAcquire(context.Background(), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locker.TryAcquire(context.Background(), "job"); err != ErrNotAcquired {
+		t.Fatalf("second acquire = %v, want ErrNotAcquired", err)
+	}
+	time.Sleep(time.Second)
+	if err := lock.Err(); err != nil {
+		t.Fatalf("lock failed while renewing: %v", err)
+	}
+	if err := lock.Release(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	next, err := locker.TryAcquire(context.Background(), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Token() <= lock.Token() {
+		t.Fatalf("fence token %d not greater than %d", next.Token(), lock.Token())
+	}
+	_ = next.Release(context.Background())
+}
+
+func TestLockLostBeforeTTLWhenRenewFails(t *testing.T) {
+	ttl := time.Second
+	locker, server := newTestLocker(t, Options{TTL: ttl, RenewInterval: time.Millisecond * 300})
+	start := time.Now()
+	lock, err := locker.TryAcquire(context.Background(), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Script(renewScript.Hash(), func(s *apptest.RedisServer, db int, keys, args []string) interface{} {
+		return errors.New("ERR unavailable")
+	})
+
+	select {
+	case <-lock.Context().Done():
+	case <-time.After(ttl * 2):
+		t.Fatal("lock context not canceled")
+	}
+	if elapsed := time.Since(start); elapsed >= ttl {
+		t.Fatalf("loss detected after %v, key already expired at %v", elapsed, ttl)
+	}
+	if err := lock.Err(); err != ErrLost {
+		t.Fatalf("Err = %v, want ErrLost", err)
+	}
+}
+
+func TestLockLostToOtherHolder(t *testing.T) {
+	locker, server := newTestLocker(t, Options{TTL: time.Second, RenewInterval: time.Millisecond * 100})
+	lock, err := locker.TryAcquire(context.Background(), "job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := redis.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Do("SET", "test:"+lockKey("job"), "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-lock.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("lock context not canceled")
+	}
+	if err := lock.Err(); err != ErrLost {
+		t.Fatalf("Err = %v, want ErrLost", err)
+	}
+	if err := lock.Release(context.Background()); err != ErrLost {
+		t.Fatalf("Release = %v, want ErrLost", err)
+	}
+}
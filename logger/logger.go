@@ -63,6 +63,8 @@ type Logger struct {
 	c           chan bool    // 通道状态
 	layout      string       // 输出时间
 	recordPool  *sync.Pool
+	mu          sync.RWMutex // 保护 closed 与向 tunnel 发送
+	closed      bool         // 已关闭，之后的记录被丢弃
 }
 
 // NewLogger 创建 Logger
@@ -131,9 +133,16 @@ func (l *Logger) Fatal(fmt string, args ...interface{}) {
 	l.deliverRecordToWriter(FATAL, fmt, args...)
 }
 
-// Close 关闭日志
+// Close 关闭日志，等待已发送的记录写入；之后的记录被丢弃，重复关闭无效
 func (l *Logger) Close() {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
 	close(l.tunnel)
+	l.mu.Unlock()
 	<-l.c
 	for _, writer := range l.writers {
 		if flush, ok := writer.(Flusher); ok {
@@ -177,6 +186,12 @@ func (l *Logger) deliverRecordToWriter(level int, format string, args ...interfa
 	record.time = l.lastTimeStr
 	record.level = level
 
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		l.recordPool.Put(record)
+		return
+	}
 	l.tunnel <- record
 }
 
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestLoggerDropsRecordsAfterClose(t *testing.T) {
+	defaultLoggerInit()
+	defaultLoggerInit() // 默认 logger 已启动，NewLogger 创建新的实例
+	l := NewLogger()
+	w := &captureWriter{records: make(chan string, 16)}
+	l.Register(w)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ { // 关闭时仍在写日志的后台任务不能 panic
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				l.Info("background %d", j)
+			}
+		}()
+	}
+	l.Info("before close")
+	l.Close()
+	wg.Wait()
+	l.Info("after close")
+	l.Close()
+
+	for len(w.records) > 0 {
+		if info := <-w.records; info == "after close" {
+			t.Fatal("record written after Close")
+		}
+	}
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -19,6 +20,9 @@ const (
 	DLTagMySQLFailed   = "_com_mysql_failure"
 	DLTagRedisFailed   = "_com_redis_failure"
 	DLTagMySQLSuccess  = "_com_mysql_success"
+	DLTagPgSQLFailed   = "_com_pgsql_failure"
+	DLTagPgSQLSuccess  = "_com_pgsql_success"
+	DLTagSQLSpan       = "_com_sql_span"
 	DLTagRedisSuccess  = "_com_redis_success"
 	DLTagThriftFailed  = "_com_thrift_failure"
 	DLTagThriftSuccess = "_com_thrift_success"
@@ -62,7 +66,7 @@ func (l *Logger) TagInfo(trace *TContext, DLTag string, m map[string]interface{}
 	m[_traceID] = trace.TraceID
 	m[_childSpanID] = trace.CSpanID
 	m[_spanID] = trace.SpanID
-	Info(ParseParams(m))
+	Info("%s", ParseParams(m))
 }
 
 // TagWarn 警告追踪
@@ -71,7 +75,7 @@ func (l *Logger) TagWarn(trace *TContext, DLTag string, m map[string]interface{}
 	m[_traceID] = trace.TraceID
 	m[_childSpanID] = trace.CSpanID
 	m[_spanID] = trace.SpanID
-	Warn(ParseParams(m))
+	Warn("%s", ParseParams(m))
 }
 
 // TagError 错误追踪
@@ -80,7 +84,7 @@ func (l *Logger) TagError(trace *TContext, DLTag string, m map[string]interface{
 	m[_traceID] = trace.TraceID
 	m[_childSpanID] = trace.CSpanID
 	m[_spanID] = trace.SpanID
-	Error(ParseParams(m))
+	Error("%s", ParseParams(m))
 }
 
 func (l *Logger) TagTrace(trace *TContext, DLTag string, m map[string]interface{}) {
@@ -88,7 +92,7 @@ func (l *Logger) TagTrace(trace *TContext, DLTag string, m map[string]interface{
 	m[_traceID] = trace.TraceID
 	m[_childSpanID] = trace.CSpanID
 	m[_spanID] = trace.SpanID
-	Trace(ParseParams(m))
+	Trace("%s", ParseParams(m))
 }
 
 // TagDebug Bug 追踪
@@ -97,7 +101,34 @@ func (l *Logger) TagDebug(trace *TContext, DLTag string, m map[string]interface{
 	m[_traceID] = trace.TraceID
 	m[_childSpanID] = trace.CSpanID
 	m[_spanID] = trace.SpanID
-	Debug(ParseParams(m))
+	Debug("%s", ParseParams(m))
+}
+
+type traceContextKey struct{}
+
+// WithTrace 将追踪信息写入上下文
+func WithTrace(ctx context.Context, trace *TContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+// TraceFromContext 从上下文获取追踪信息，不存在时返回新的追踪信息
+func TraceFromContext(ctx context.Context) *TContext {
+	if ctx != nil {
+		if trace, ok := ctx.Value(traceContextKey{}).(*TContext); ok && trace != nil {
+			return trace
+		}
+	}
+	return NewTrace()
+}
+
+// TraceIDFromContext 从上下文获取链路ID，不存在时返回空字符串
+func TraceIDFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if trace, ok := ctx.Value(traceContextKey{}).(*TContext); ok && trace != nil {
+			return trace.TraceID
+		}
+	}
+	return ""
 }
 
 func NewTrace() *TContext {
@@ -158,7 +189,7 @@ func checkDLTag(tag string) string {
 	return tag
 }
 
-// ParseParams map格式化为string
+// ParseParams map格式化为string，字段值可能含有 %，结果不能直接作为格式串
 func ParseParams(m map[string]interface{}) string {
 	var tag = "_undef"
 	if _tag, _have := m["dl_tag"]; _have {
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureWriter 将日志内容写入通道
+type captureWriter struct {
+	records chan string
+}
+
+func (w *captureWriter) Init() error { return nil }
+
+func (w *captureWriter) Write(r *Record) error {
+	select {
+	case w.records <- r.info:
+	default:
+	}
+	return nil
+}
+
+var (
+	capture     = &captureWriter{records: make(chan string, 1)}
+	captureOnce sync.Once
+)
+
+func TestTagInfoKeepsPercent(t *testing.T) {
+	captureOnce.Do(func() {
+		defaultLoggerInit()
+		loggerDefault.Register(capture)
+	})
+
+	loggerDefault.TagInfo(&TContext{}, DLTagUndefined, map[string]interface{}{"sql": "SELECT * FROM users WHERE name LIKE '%foo%'"})
+	select {
+	case info := <-capture.records:
+		if !strings.Contains(info, "LIKE '%foo%'") {
+			t.Fatalf("info = %s", info)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no record written")
+	}
+}
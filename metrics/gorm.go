@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"gorm.io/gorm"
+)
+
+const sqlStartKey = "scaffold:metrics_start" // 语句开始时间
+
+var (
+	sqlLabels   = []string{"driver", "pool", "operation", "table"}
+	sqlDuration = NewHistogramVec("scaffold_db_query_duration_seconds", "SQL statement latency by operation and table.", nil, sqlLabels...)
+	sqlRows     = NewCounterVec("scaffold_db_query_rows_total", "Rows returned or affected by SQL statements.", sqlLabels...)
+	sqlErrors   = NewCounterVec("scaffold_db_query_errors_total", "SQL statements that returned an error.", sqlLabels...)
+
+	registerSQLOnce sync.Once
+)
+
+// GormPlugin 记录每条语句耗时、行数与错误的 gorm 插件
+type GormPlugin struct {
+	driver string
+	pool   string
+	spans  bool
+}
+
+// NewGormPlugin 创建 gorm 插件，spans 为 true 时为每条语句输出追踪 span 日志
+func NewGormPlugin(driver, pool string, spans bool) *GormPlugin {
+	return &GormPlugin{driver: driver, pool: pool, spans: spans}
+}
+
+// RegisterSQLPlugin 在默认注册表注册 SQL 指标，并为所有命名连接池注册插件
+func RegisterSQLPlugin(spans bool) error {
+	registerSQLOnce.Do(func() {
+		Default.Register(sqlDuration)
+		Default.Register(sqlRows)
+		Default.Register(sqlErrors)
+	})
+	return app.RegisterSQLPlugin(func(driver, name string) gorm.Plugin {
+		return NewGormPlugin(driver, name, spans)
+	})
+}
+
+// Name 插件名称
+func (p *GormPlugin) Name() string {
+	return "scaffold:metrics"
+}
+
+// Initialize 注册回调
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		registerAround(p, "create", cb.Create().Before("gorm:create"), cb.Create().After("gorm:create")),
+		registerAround(p, "query", cb.Query().Before("gorm:query"), cb.Query().After("gorm:query")),
+		registerAround(p, "update", cb.Update().Before("gorm:update"), cb.Update().After("gorm:update")),
+		registerAround(p, "delete", cb.Delete().Before("gorm:delete"), cb.Delete().After("gorm:delete")),
+		registerAround(p, "row", cb.Row().Before("gorm:row"), cb.Row().After("gorm:row")),
+		registerAround(p, "raw", cb.Raw().Before("gorm:raw"), cb.Raw().After("gorm:raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerAround 在 gorm 内置回调前后注册计时回调
+func registerAround[C interface {
+	Register(name string, fn func(*gorm.DB)) error
+}](p *GormPlugin, op string, before, after C) error {
+	if err := before.Register("scaffold:metrics_before_"+op, p.before); err != nil {
+		return err
+	}
+	return after.Register("scaffold:metrics_after_"+op, p.after(op))
+}
+
+func (p *GormPlugin) before(db *gorm.DB) {
+	db.InstanceSet(sqlStartKey, time.Now())
+}
+
+func (p *GormPlugin) after(op string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(sqlStartKey)
+		if !ok {
+			return
+		}
+		begin, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(begin)
+		table := db.Statement.Table
+		if table == "" && db.Statement.Schema != nil {
+			table = db.Statement.Schema.Table
+		}
+		sqlDuration.Observe(elapsed.Seconds(), p.driver, p.pool, op, table)
+		if db.RowsAffected > 0 {
+			sqlRows.Add(float64(db.RowsAffected), p.driver, p.pool, op, table)
+		}
+		failed := db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound)
+		if failed {
+			sqlErrors.Inc(p.driver, p.pool, op, table)
+		}
+		if p.spans {
+			p.span(db, op, table, begin, elapsed, failed)
+		}
+	}
+}
+
+// span 在请求追踪上下文中输出子 span 日志
+func (p *GormPlugin) span(db *gorm.DB, op, table string, begin time.Time, elapsed time.Duration, failed bool) {
+	trace := *logger.TraceFromContext(db.Statement.Context)
+	trace.CSpanID = logger.NewSpanID()
+	m := map[string]interface{}{
+		"driver":    p.driver,
+		"pool":      p.pool,
+		"operation": op,
+		"table":     table,
+		"rows":      db.RowsAffected,
+		"start":     begin.Format(time.RFC3339Nano),
+		"proc_time": elapsed.Seconds(),
+	}
+	if failed {
+		m["error"] = db.Error.Error()
+		logger.Log.TagError(&trace, logger.DLTagSQLSpan, m)
+		return
+	}
+	logger.Log.TagInfo(&trace, logger.DLTagSQLSpan, m)
+}
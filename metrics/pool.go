@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+
+	"github.com/gomodule/redigo/redis"
+	"gorm.io/gorm"
+)
+
+// dbStatsDesc sql.DBStats 指标描述
+var dbStatsDesc = []struct {
+	name, help, typ string
+	value           func(sql.DBStats) float64
+}{
+	{"scaffold_db_open_connections", "Number of established connections both in use and idle.", TypeGauge,
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+	{"scaffold_db_in_use_connections", "Number of connections currently in use.", TypeGauge,
+		func(s sql.DBStats) float64 { return float64(s.InUse) }},
+	{"scaffold_db_idle_connections", "Number of idle connections.", TypeGauge,
+		func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	{"scaffold_db_max_open_connections", "Maximum number of open connections to the database.", TypeGauge,
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+	{"scaffold_db_wait_count_total", "Total number of connections waited for.", TypeCounter,
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+	{"scaffold_db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", TypeCounter,
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+	{"scaffold_db_max_idle_closed_total", "Total number of connections closed due to max idle limits.", TypeCounter,
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+	{"scaffold_db_max_lifetime_closed_total", "Total number of connections closed due to max lifetime.", TypeCounter,
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+}
+
+// redisStatsDesc redis.PoolStats 指标描述
+var redisStatsDesc = []struct {
+	name, help, typ string
+	value           func(redis.PoolStats) float64
+}{
+	{"scaffold_redis_active_connections", "Number of connections in the pool, idle and in use.", TypeGauge,
+		func(s redis.PoolStats) float64 { return float64(s.ActiveCount) }},
+	{"scaffold_redis_idle_connections", "Number of idle connections in the pool.", TypeGauge,
+		func(s redis.PoolStats) float64 { return float64(s.IdleCount) }},
+	{"scaffold_redis_wait_count_total", "Total number of connections waited for.", TypeCounter,
+		func(s redis.PoolStats) float64 { return float64(s.WaitCount) }},
+	{"scaffold_redis_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", TypeCounter,
+		func(s redis.PoolStats) float64 { return s.WaitDuration.Seconds() }},
+}
+
+// PoolCollector 采集 MySQL / Postgres / Redis 命名连接池统计
+type PoolCollector struct{}
+
+// RegisterPools 在默认注册表注册连接池采集器
+func RegisterPools() {
+	Default.Register(PoolCollector{})
+}
+
+// Collect 采集连接池统计，role 标签区分主库 (primary) 与只读副本 (replica)，副本另有 replica 序号标签
+func (PoolCollector) Collect() []Metric {
+	list := make([]Metric, 0, len(dbStatsDesc)+len(redisStatsDesc))
+
+	type dbSample struct {
+		labels Labels
+		stats  sql.DBStats
+	}
+	var dbSamples []dbSample
+	addDB := func(driver string, pools map[string]*gorm.DB, replicas map[string][]*sql.DB) {
+		for _, name := range sortedNames(pools) {
+			db, err := pools[name].DB()
+			if err != nil {
+				continue
+			}
+			dbSamples = append(dbSamples, dbSample{Labels{"pool": name, "driver": driver, "role": "primary"}, db.Stats()})
+			for i, replica := range replicas[name] {
+				labels := Labels{"pool": name, "driver": driver, "role": "replica", "replica": strconv.Itoa(i)}
+				dbSamples = append(dbSamples, dbSample{labels, replica.Stats()})
+			}
+		}
+	}
+	addDB("mysql", app.MySQLPools(), app.MySQLReplicas())
+	addDB("postgres", app.PgSQLPools(), app.PgSQLReplicas())
+	for _, desc := range dbStatsDesc {
+		metric := Metric{Name: desc.name, Help: desc.help, Type: desc.typ}
+		for _, s := range dbSamples {
+			metric.Samples = append(metric.Samples, Sample{Labels: s.labels, Value: desc.value(s.stats)})
+		}
+		list = append(list, metric)
+	}
+
+	type redisSample struct {
+		labels Labels
+		stats  redis.PoolStats
+	}
+	var redisSamples []redisSample
+	addRedis := func(role string, pools map[string]*redis.Pool) {
+		for _, name := range sortedNames(pools) {
+			labels := Labels{"pool": name, "driver": "redis", "role": role}
+			redisSamples = append(redisSamples, redisSample{labels, pools[name].Stats()})
+		}
+	}
+	addRedis("primary", app.RedisPools())
+	addRedis("replica", app.RedisReplicaPools())
+	for _, desc := range redisStatsDesc {
+		metric := Metric{Name: desc.name, Help: desc.help, Type: desc.typ}
+		for _, s := range redisSamples {
+			metric.Samples = append(metric.Samples, Sample{Labels: s.labels, Value: desc.value(s.stats)})
+		}
+		list = append(list, metric)
+	}
+	return list
+}
+
+// sortedNames 排序后的连接池名称
+func sortedNames[T any](pools map[string]T) []string {
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/MetaverseTopDJ/Scaffold/apptest"
+)
+
+func TestPoolCollectorLabels(t *testing.T) {
+	apptest.New(t, apptest.WithMySQL("default"), apptest.WithRedis("cache"))
+	found := map[string]Labels{}
+	for _, metric := range (PoolCollector{}).Collect() {
+		for _, sample := range metric.Samples {
+			found[metric.Name+"/"+sample.Labels["driver"]+"/"+sample.Labels["pool"]] = sample.Labels
+		}
+	}
+	for _, key := range []string{
+		"scaffold_db_open_connections/mysql/default",
+		"scaffold_db_max_open_connections/mysql/default",
+		"scaffold_redis_active_connections/redis/cache",
+	} {
+		labels, ok := found[key]
+		if !ok {
+			t.Fatalf("missing sample %s in %v", key, found)
+		}
+		if labels["role"] != "primary" {
+			t.Fatalf("%s role = %q, want primary", key, labels["role"])
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 指标类型
+const (
+	TypeGauge     = "gauge"
+	TypeCounter   = "counter"
+	TypeHistogram = "histogram"
+)
+
+// Labels 指标标签
+type Labels map[string]string
+
+// Sample 指标样本
+type Sample struct {
+	Suffix string // 名称后缀，如直方图的 _bucket _sum _count
+	Labels Labels
+	Value  float64
+}
+
+// Metric 指标
+type Metric struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Collector 指标采集器
+type Collector interface {
+	Collect() []Metric
+}
+
+// CollectorFunc 函数形式的采集器
+type CollectorFunc func() []Metric
+
+func (f CollectorFunc) Collect() []Metric {
+	return f()
+}
+
+// Registry 指标注册表，定时采样各采集器并缓存结果
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+	cache      []Metric
+	sampled    bool
+	stop       chan struct{}
+}
+
+// Default 默认注册表
+var Default = NewRegistry()
+
+// NewRegistry 创建注册表
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register 注册采集器
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+	r.sampled = false
+}
+
+// Sample 立即采样所有采集器
+func (r *Registry) Sample() {
+	r.mu.RLock()
+	collectors := make([]Collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mu.RUnlock()
+
+	var list []Metric
+	for _, c := range collectors {
+		list = append(list, c.Collect()...)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	r.mu.Lock()
+	r.cache = list
+	r.sampled = true
+	r.mu.Unlock()
+}
+
+// Start 开始定时采样
+func (r *Registry) Start(interval time.Duration) {
+	r.mu.Lock()
+	if r.stop != nil {
+		r.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	r.stop = stop
+	r.mu.Unlock()
+
+	r.Sample()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.Sample()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定时采样
+func (r *Registry) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+}
+
+// Metrics 定时采样时返回最近一次采样结果，未启动定时采样或尚未采样时立即采样
+func (r *Registry) Metrics() []Metric {
+	r.mu.RLock()
+	cached := r.sampled && r.stop != nil
+	r.mu.RUnlock()
+	if !cached {
+		r.Sample()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cache
+}
+
+// WriteText 以 Prometheus 文本格式输出
+func (r *Registry) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	merged := map[string]bool{}
+	list := r.Metrics()
+	for _, metric := range list {
+		if !merged[metric.Name] {
+			merged[metric.Name] = true
+			if metric.Help != "" {
+				fmt.Fprintf(bw, "# HELP %s %s\n", metric.Name, escapeHelp(metric.Help))
+			}
+			fmt.Fprintf(bw, "# TYPE %s %s\n", metric.Name, metric.Type)
+		}
+		for _, sample := range metric.Samples {
+			bw.WriteString(metric.Name + sample.Suffix)
+			writeLabels(bw, sample.Labels)
+			bw.WriteString(" " + formatValue(sample.Value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+// ServeHTTP 实现 http.Handler
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.WriteText(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Handler 默认注册表的 HTTP 处理器
+func Handler() http.Handler {
+	return Default
+}
+
+// writeLabels 按名称排序输出标签
+func writeLabels(w *bufio.Writer, labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(k + `="` + escapeLabel(labels[k]) + `"`)
+	}
+	w.WriteByte('}')
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+// formatValue 格式化样本值
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricsSamplesEachScrapeWithoutTicker(t *testing.T) {
+	r := NewRegistry()
+	var n float64
+	r.Register(CollectorFunc(func() []Metric {
+		n++
+		return []Metric{{Name: "scrapes", Type: "gauge", Samples: []Sample{{Value: n}}}}
+	}))
+	for want := 1.0; want <= 3; want++ {
+		if got := r.Metrics()[0].Samples[0].Value; got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+
+	r.Start(time.Hour)
+	defer r.Stop()
+	cached := r.Metrics()[0].Samples[0].Value
+	if got := r.Metrics()[0].Samples[0].Value; got != cached {
+		t.Fatalf("ticker running: got %v, want cached %v", got, cached)
+	}
+}
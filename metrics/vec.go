@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets 默认耗时直方图分桶 (秒)
+var DefaultBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+	mu         sync.Mutex
+	series     map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labels Labels
+	value  float64
+}
+
+// NewCounterVec 创建计数器
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{name: name, help: help, labelNames: labelNames, series: map[string]*counterSeries{}}
+}
+
+// Add 增加计数，标签值顺序与创建时的标签名一致
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{labels: makeLabels(c.labelNames, labelValues)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+// Inc 计数加一
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Collect 实现 Collector
+func (c *CounterVec) Collect() []Metric {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	metric := Metric{Name: c.name, Help: c.help, Type: TypeCounter}
+	for _, key := range sortedNames(c.series) {
+		s := c.series[key]
+		metric.Samples = append(metric.Samples, Sample{Labels: s.labels, Value: s.value})
+	}
+	return []Metric{metric}
+}
+
+// HistogramVec 带标签的直方图
+type HistogramVec struct {
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+	mu         sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels Labels
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec 创建直方图，buckets 为空时使用 DefaultBuckets
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+	return &HistogramVec{name: name, help: help, buckets: sorted, labelNames: labelNames, series: map[string]*histogramSeries{}}
+}
+
+// Observe 记录观测值，标签值顺序与创建时的标签名一致
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labels: makeLabels(h.labelNames, labelValues), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// Collect 实现 Collector
+func (h *HistogramVec) Collect() []Metric {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	metric := Metric{Name: h.name, Help: h.help, Type: TypeHistogram}
+	for _, key := range sortedNames(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			metric.Samples = append(metric.Samples, Sample{Suffix: "_bucket", Labels: withLabel(s.labels, "le", formatValue(upper)), Value: float64(cumulative)})
+		}
+		metric.Samples = append(metric.Samples,
+			Sample{Suffix: "_bucket", Labels: withLabel(s.labels, "le", formatValue(math.Inf(1))), Value: float64(s.count)},
+			Sample{Suffix: "_sum", Labels: s.labels, Value: s.sum},
+			Sample{Suffix: "_count", Labels: s.labels, Value: float64(s.count)},
+		)
+	}
+	return []Metric{metric}
+}
+
+// makeLabels 组合标签名与标签值
+func makeLabels(names, values []string) Labels {
+	labels := make(Labels, len(names))
+	for i, name := range names {
+		if i < len(values) {
+			labels[name] = values[i]
+		} else {
+			labels[name] = ""
+		}
+	}
+	return labels
+}
+
+// withLabel 复制标签并追加一个标签
+func withLabel(labels Labels, name, value string) Labels {
+	copied := make(Labels, len(labels)+1)
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+)
+
+// locker 数据库咨询锁，防止多个进程同时执行迁移
+type locker struct {
+	conn    *sql.Conn
+	dialect string
+	name    string
+}
+
+// lockKey 根据名称计算 Postgres 咨询锁键值
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// acquireLock 获取咨询锁，锁绑定在独占连接上
+func acquireLock(ctx context.Context, db *sql.DB, dialect, name string) (*locker, error) {
+	l := &locker{dialect: dialect, name: name}
+	if dialect != "postgres" && dialect != "mysql" {
+		return l, nil // 其他数据库不支持咨询锁
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	l.conn = conn
+	switch dialect {
+	case "postgres":
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey(name))
+	case "mysql":
+		var got sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&got)
+		if err == nil && got.Int64 != 1 {
+			err = fmt.Errorf("get lock %s failed", name)
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("acquire migration lock failed: %v", err)
+	}
+	return l, nil
+}
+
+// release 释放咨询锁
+func (l *locker) release() error {
+	if l.conn == nil {
+		return nil
+	}
+	defer l.conn.Close()
+	var err error
+	switch l.dialect {
+	case "postgres":
+		_, err = l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey(l.name))
+	case "mysql":
+		_, err = l.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", l.name)
+	}
+	return err
+}
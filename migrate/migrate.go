@@ -0,0 +1,275 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"gorm.io/gorm"
+)
+
+const defaultTable = "schema_migrations" // 默认版本记录表
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255"`
+	Checksum  string    `gorm:"size:64"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool      // 是否已执行
+	AppliedAt time.Time // 执行时间
+	Modified  bool      // 已执行的迁移内容被修改
+	Missing   bool      // 已执行但本地不存在
+}
+
+// Migrator 版本迁移器
+type Migrator struct {
+	db         *gorm.DB
+	table      string
+	migrations map[int64]*Migration
+}
+
+// New 创建迁移器
+func New(db *gorm.DB) *Migrator {
+	return &Migrator{db: db, table: defaultTable, migrations: map[int64]*Migration{}}
+}
+
+// SetTable 设置版本记录表名
+func (m *Migrator) SetTable(table string) *Migrator {
+	m.table = table
+	return m
+}
+
+// Migrations 按版本排序的迁移列表
+func (m *Migrator) Migrations() []*Migration {
+	list := make([]*Migration, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		list = append(list, migration)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// Up 执行未执行的迁移，steps 为 0 时执行全部
+func (m *Migrator) Up(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		n := 0
+		for _, migration := range m.Migrations() {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if steps > 0 && n >= steps {
+				break
+			}
+			if err := m.apply(db, migration); err != nil {
+				return err
+			}
+			n++
+		}
+		logger.Info("migrate up: %d migration(s) applied", n)
+		return nil
+	})
+}
+
+// Down 回滚最近执行的迁移，steps 小于等于 0 时回滚一个
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		steps = 1
+	}
+	return m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		versions := latest(applied)
+		for i := 0; i < steps && i < len(versions); i++ {
+			migration, ok := m.migrations[versions[i]]
+			if !ok {
+				return fmt.Errorf("migration %d is applied but not found", versions[i])
+			}
+			if err := m.rollback(db, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Redo 回滚并重新执行最近一次迁移，两步在同一把锁内完成
+func (m *Migrator) Redo(ctx context.Context) error {
+	return m.withLock(ctx, func(db *gorm.DB) error {
+		applied, err := m.applied(db)
+		if err != nil {
+			return err
+		}
+		versions := latest(applied)
+		if len(versions) == 0 {
+			logger.Info("migrate redo: no applied migration")
+			return nil
+		}
+		migration, ok := m.migrations[versions[0]]
+		if !ok {
+			return fmt.Errorf("migration %d is applied but not found", versions[0])
+		}
+		if err := m.rollback(db, migration); err != nil {
+			return err
+		}
+		return m.apply(db, migration)
+	})
+}
+
+// Status 获取所有迁移的状态
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.db.WithContext(ctx)
+	if err := m.ensureTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(db)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.Migrations() {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+			status.Modified = record.Checksum != migration.Checksum()
+			delete(applied, migration.Version)
+		}
+		list = append(list, status)
+	}
+	for _, record := range applied {
+		list = append(list, Status{
+			Version:   record.Version,
+			Name:      record.Name,
+			Applied:   true,
+			AppliedAt: record.AppliedAt,
+			Missing:   true,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// withLock 在咨询锁保护下执行
+func (m *Migrator) withLock(ctx context.Context, fc func(db *gorm.DB) error) (err error) {
+	db := m.db.WithContext(ctx)
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	lock, err := acquireLock(ctx, sqlDB, db.Dialector.Name(), m.table)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if releaseErr := lock.release(); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+	}()
+	if err = m.ensureTable(db); err != nil {
+		return err
+	}
+	return fc(db)
+}
+
+// ensureTable 创建版本记录表
+func (m *Migrator) ensureTable(db *gorm.DB) error {
+	return db.Table(m.table).AutoMigrate(&SchemaMigration{})
+}
+
+// applied 已执行的迁移记录
+func (m *Migrator) applied(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	var records []SchemaMigration
+	if err := db.Table(m.table).Order("version").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// latest 已执行的版本，从新到旧排序
+func latest(applied map[int64]SchemaMigration) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	return versions
+}
+
+// verify 校验已执行迁移的内容是否被修改
+func (m *Migrator) verify(applied map[int64]SchemaMigration) error {
+	for version, record := range applied {
+		migration, ok := m.migrations[version]
+		if !ok {
+			continue
+		}
+		if record.Checksum != migration.Checksum() {
+			return fmt.Errorf("migration %d_%s checksum mismatch", version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// apply 在事务中执行升级并记录版本
+func (m *Migrator) apply(db *gorm.DB, migration *Migration) error {
+	begin := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.runUp(tx); err != nil {
+			return err
+		}
+		return tx.Table(m.table).Create(&SchemaMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Checksum:  migration.Checksum(),
+			AppliedAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate up %d_%s failed: %v", migration.Version, migration.Name, err)
+	}
+	logger.Info("migrate up: %d_%s (%v)", migration.Version, migration.Name, time.Since(begin))
+	return nil
+}
+
+// rollback 在事务中执行回滚并删除版本记录
+func (m *Migrator) rollback(db *gorm.DB, migration *Migration) error {
+	begin := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.runDown(tx); err != nil {
+			return err
+		}
+		result := tx.Table(m.table).Where("version = ?", migration.Version).Delete(&SchemaMigration{})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return errors.New("version record not found")
+		}
+		return result.Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate down %d_%s failed: %v", migration.Version, migration.Name, err)
+	}
+	logger.Info("migrate down: %d_%s (%v)", migration.Version, migration.Name, time.Since(begin))
+	return nil
+}
@@ -0,0 +1,169 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// newTestMigrator 版本 1、3 为 SQL 迁移，版本 2 为 Go 函数迁移，runs 记录版本 2 的执行情况
+func newTestMigrator(t *testing.T, db *gorm.DB, runs *[]string) *Migrator {
+	t.Helper()
+	m := New(db)
+	err := m.LoadFS(fstest.MapFS{
+		"1_users.up.sql":    {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY)")},
+		"1_users.down.sql":  {Data: []byte("DROP TABLE users")},
+		"3_orders.up.sql":   {Data: []byte("CREATE TABLE orders (id INTEGER PRIMARY KEY)")},
+		"3_orders.down.sql": {Data: []byte("DROP TABLE orders")},
+		"readme.md":         {Data: []byte("ignored")},
+		"sub/4_skip.up.sql": {Data: []byte("invalid")},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Register(2, "user_email", func(tx *gorm.DB) error {
+		*runs = append(*runs, "up")
+		return tx.Exec("ALTER TABLE users ADD COLUMN email TEXT").Error
+	}, func(tx *gorm.DB) error {
+		*runs = append(*runs, "down")
+		return tx.Exec("ALTER TABLE users DROP COLUMN email").Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// statusOf 按版本顺序返回状态摘要，如 "1/applied 2/pending"
+func statusOf(t *testing.T, m *Migrator) string {
+	t.Helper()
+	list, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make([]string, len(list))
+	for i, status := range list {
+		state := "pending"
+		if status.Applied {
+			state = "applied"
+		}
+		if status.Modified {
+			state += "(modified)"
+		}
+		if status.Missing {
+			state += "(missing)"
+		}
+		parts[i] = fmt.Sprintf("%d/%s", status.Version, state)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db := openTestDB(t)
+	var runs []string
+	m := newTestMigrator(t, db, &runs)
+	ctx := context.Background()
+	if got := statusOf(t, m); got != "1/pending 2/pending 3/pending" {
+		t.Fatalf("initial status = %s", got)
+	}
+
+	if err := m.Up(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := statusOf(t, m); got != "1/applied 2/applied 3/pending" {
+		t.Fatalf("status after up 2 = %s", got)
+	}
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Migrator().HasTable("orders") {
+		t.Fatal("orders table not created")
+	}
+
+	if err := m.Down(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := statusOf(t, m); got != "1/applied 2/pending 3/pending" {
+		t.Fatalf("status after down 2 = %s", got)
+	}
+	if db.Migrator().HasTable("orders") || db.Migrator().HasColumn("users", "email") {
+		t.Fatal("down steps not executed")
+	}
+	if strings.Join(runs, ",") != "up,down" {
+		t.Fatalf("go migration runs = %v", runs)
+	}
+}
+
+func TestRedoReappliesLatest(t *testing.T) {
+	db := openTestDB(t)
+	var runs []string
+	m := newTestMigrator(t, db, &runs)
+	ctx := context.Background()
+	if err := m.Redo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := statusOf(t, m); got != "1/pending 2/pending 3/pending" {
+		t.Fatalf("redo without applied migration = %s", got)
+	}
+
+	if err := m.Up(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	runs = nil
+	// 版本 3 未执行，Redo 只能重新执行回滚的版本 2
+	if err := m.Redo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(runs, ",") != "down,up" {
+		t.Fatalf("go migration runs = %v", runs)
+	}
+	if got := statusOf(t, m); got != "1/applied 2/applied 3/pending" {
+		t.Fatalf("status after redo = %s", got)
+	}
+	if !db.Migrator().HasColumn("users", "email") {
+		t.Fatal("email column missing after redo")
+	}
+}
+
+func TestStatusModifiedAndMissing(t *testing.T) {
+	db := openTestDB(t)
+	var runs []string
+	ctx := context.Background()
+	if err := newTestMigrator(t, db, &runs).Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTestMigrator(t, db, &runs)
+	m.migrations[1].UpSQL = "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)"
+	delete(m.migrations, 3)
+	if got := statusOf(t, m); got != "1/applied(modified) 2/applied 3/applied(missing)" {
+		t.Fatalf("status = %s", got)
+	}
+	if err := m.Up(ctx, 0); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Up with modified migration = %v", err)
+	}
+	if err := m.Down(ctx, 1); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Down with missing migration = %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// 迁移文件命名格式 {version}_{name}.up.sql / {version}_{name}.down.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// MigrateFunc Go 函数迁移，在事务中执行
+type MigrateFunc func(tx *gorm.DB) error
+
+// Migration 单个版本的迁移
+type Migration struct {
+	Version int64       // 版本号
+	Name    string      // 名称
+	UpSQL   string      // 升级 SQL
+	DownSQL string      // 回滚 SQL
+	Up      MigrateFunc // 升级函数，优先于 UpSQL
+	Down    MigrateFunc // 回滚函数，优先于 DownSQL
+}
+
+// Checksum SQL 迁移内容的校验和，Go 函数迁移为空
+func (m *Migration) Checksum() string {
+	if m.UpSQL == "" && m.DownSQL == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(m.UpSQL + "\x00" + m.DownSQL))
+	return hex.EncodeToString(sum[:])
+}
+
+// runUp 执行升级
+func (m *Migration) runUp(tx *gorm.DB) error {
+	if m.Up != nil {
+		return m.Up(tx)
+	}
+	if m.UpSQL == "" {
+		return nil
+	}
+	return tx.Exec(m.UpSQL).Error
+}
+
+// runDown 执行回滚
+func (m *Migration) runDown(tx *gorm.DB) error {
+	if m.Down != nil {
+		return m.Down(tx)
+	}
+	if m.DownSQL == "" {
+		return fmt.Errorf("migration %d_%s has no down step", m.Version, m.Name)
+	}
+	return tx.Exec(m.DownSQL).Error
+}
+
+// LoadDir 从目录加载 SQL 迁移文件
+func (m *Migrator) LoadDir(dir string) error {
+	return m.LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS 从文件系统 (如 embed.FS) 加载 SQL 迁移文件
+func (m *Migrator) LoadFS(fsys fs.FS, root string) error {
+	entries, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		return fmt.Errorf("read migrations %s failed: %v", root, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid migration version %s: %v", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(root, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("read migration %s failed: %v", entry.Name(), err)
+		}
+		migration, err := m.migration(version, match[2])
+		if err != nil {
+			return err
+		}
+		if match[3] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+	return nil
+}
+
+// Register 注册 Go 函数迁移
+func (m *Migrator) Register(version int64, name string, up, down MigrateFunc) error {
+	migration, err := m.migration(version, name)
+	if err != nil {
+		return err
+	}
+	migration.Up = up
+	migration.Down = down
+	return nil
+}
+
+// migration 获取或创建指定版本的迁移
+func (m *Migrator) migration(version int64, name string) (*Migration, error) {
+	if migration, ok := m.migrations[version]; ok {
+		if migration.Name != name {
+			return nil, fmt.Errorf("duplicated migration version %d: %s, %s", version, migration.Name, name)
+		}
+		return migration, nil
+	}
+	migration := &Migration{Version: version, Name: name}
+	m.migrations[version] = migration
+	return migration, nil
+}
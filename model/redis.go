@@ -5,14 +5,27 @@ type RedisMapConfig struct {
 }
 
 type RedisConfig struct {
-	ProxyList    string `mapstructure:"proxy_list"`
-	Password     string `mapstructure:"password"`
-	Prefix       string `mapstructure:"prefix"`
-	Db           int    `mapstructure:"db"`
-	MaxIdle      int    `mapstructure:"max_idle"`
-	MaxActive    int    `mapstructure:"max_active"`
-	ConnTimeout  int    `mapstructure:"conn_timeout"`
-	IdelTimeout  int    `mapstructure:"idle_timeout"`
-	ReadTimeout  int    `mapstructure:"read_timeout"`
-	WriteTimeout int    `mapstructure:"write_timeout"`
+	ProxyList       string `mapstructure:"proxy_list"`
+	Username        string `mapstructure:"username"` // ACL 用户名，Redis 6+
+	Password        string `mapstructure:"password"`
+	Prefix          string `mapstructure:"prefix"` // 键前缀，RedisClient 自动添加
+	Db              int    `mapstructure:"db"`
+	MaxIdle         int    `mapstructure:"max_idle"`
+	MaxActive       int    `mapstructure:"max_active"`
+	MaxConnLifetime int    `mapstructure:"max_conn_lifetime"` // 连接最长使用时间 (秒)，0 表示不限制
+	ConnTimeout     int    `mapstructure:"conn_timeout"`      // 连接超时 (秒)
+	IdelTimeout     int    `mapstructure:"idle_timeout"`      // 空闲连接超时 (秒)
+	ReadTimeout     int    `mapstructure:"read_timeout"`      // 读超时 (秒)
+	WriteTimeout    int    `mapstructure:"write_timeout"`     // 写超时 (秒)
+
+	Sentinels        []string `mapstructure:"sentinels"`          // 哨兵地址，不为空时通过哨兵发现主节点，忽略 proxy_list
+	MasterName       string   `mapstructure:"master_name"`        // 哨兵监控的主节点名称
+	SentinelPassword string   `mapstructure:"sentinel_password"`  // 哨兵密码
+	ReadFromReplicas bool     `mapstructure:"read_from_replicas"` // RedisClient 的读命令发送到从节点
+
+	ClusterNodes []string `mapstructure:"cluster_nodes"` // 集群种子节点，不为空时使用集群模式，忽略 proxy_list 与 db
+
+	HealthCheckInterval int `mapstructure:"health_check_interval"` // 借出空闲超过该时间 (秒) 的连接前 PING，默认 60，-1 不检查
+	BreakerThreshold    int `mapstructure:"breaker_threshold"`     // 连续失败多少次后熔断，默认 5，-1 不熔断
+	BreakerTimeout      int `mapstructure:"breaker_timeout"`       // 熔断多久 (秒) 后探测恢复，默认 10
 }
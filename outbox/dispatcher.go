@@ -0,0 +1,306 @@
+package outbox
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 默认投递参数
+const (
+	defaultBatchSize       = 100
+	defaultPollInterval    = time.Second
+	defaultBackoff         = time.Second
+	defaultMaxBackoff      = time.Minute * 5
+	defaultRetention       = time.Hour * 24
+	defaultCleanupInterval = time.Minute
+	defaultSendTimeout     = time.Second * 30
+	defaultClaimTimeout    = time.Minute
+)
+
+// Config 投递配置
+type Config struct {
+	BatchSize       int           // 每次认领的事件数，默认 100
+	PollInterval    time.Duration // 轮询间隔，默认 1s
+	MaxAttempts     int           // 最大投递次数，超过后标记为 failed，0 表示一直重试
+	Backoff         time.Duration // 初始重试间隔，默认 1s
+	MaxBackoff      time.Duration // 最大重试间隔，默认 5m
+	SendTimeout     time.Duration // 单个事件投递超时，默认 30s
+	ClaimTimeout    time.Duration // 认领租期，即整批投递的时限，默认 1m 且不小于 SendTimeout；到期未投递的事件由其他实例重新认领
+	Retention       time.Duration // 已投递事件保留时间，默认 24h，小于 0 时不清理
+	CleanupInterval time.Duration // 清理间隔，默认 1m
+}
+
+// Dispatcher 后台认领并投递发件箱事件，多个实例可同时运行
+type Dispatcher struct {
+	getDB  func() (*gorm.DB, error)
+	sink   Sink
+	config Config
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewDispatcher 使用固定的连接池创建投递器；命名连接池重建 (app.ReloadMySQLPool 等) 后旧连接池会被关闭，
+// 此时应使用 NewMySQLDispatcher / NewPgSQLDispatcher 或 NewDispatcherFunc 每次获取连接池
+func NewDispatcher(db *gorm.DB, sink Sink, config Config) *Dispatcher {
+	return NewDispatcherFunc(func() (*gorm.DB, error) { return db, nil }, sink, config)
+}
+
+// NewMySQLDispatcher 创建使用 MySQL 命名连接池的投递器
+func NewMySQLDispatcher(pool string, sink Sink, config Config) *Dispatcher {
+	return NewDispatcherFunc(func() (*gorm.DB, error) { return app.GetMySQLPool(pool) }, sink, config)
+}
+
+// NewPgSQLDispatcher 创建使用 Postgres 命名连接池的投递器
+func NewPgSQLDispatcher(pool string, sink Sink, config Config) *Dispatcher {
+	return NewDispatcherFunc(func() (*gorm.DB, error) { return app.GetPgSQLPool(pool) }, sink, config)
+}
+
+// NewDispatcherFunc 创建投递器，每次访问数据库时通过 getDB 获取连接池
+func NewDispatcherFunc(getDB func() (*gorm.DB, error), sink Sink, config Config) *Dispatcher {
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = defaultBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaultMaxBackoff
+	}
+	if config.SendTimeout <= 0 {
+		config.SendTimeout = defaultSendTimeout
+	}
+	if config.ClaimTimeout <= 0 {
+		config.ClaimTimeout = defaultClaimTimeout
+	}
+	if config.ClaimTimeout < config.SendTimeout {
+		config.ClaimTimeout = config.SendTimeout
+	}
+	if config.Retention == 0 {
+		config.Retention = defaultRetention
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = defaultCleanupInterval
+	}
+	return &Dispatcher{getDB: getDB, sink: sink, config: config}
+}
+
+// Start 开始后台投递
+func (d *Dispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	d.stop, d.done = stop, done
+	go d.run(stop, done)
+}
+
+// Stop 停止后台投递，等待进行中的批次完成
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.stop, d.done = nil, nil
+	d.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (d *Dispatcher) run(stop, done chan struct{}) {
+	defer close(done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	poll := time.NewTimer(0)
+	defer poll.Stop()
+	cleanup := time.NewTicker(d.config.CleanupInterval)
+	defer cleanup.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-cleanup.C:
+			if _, err := d.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("outbox cleanup failed: %v", err)
+			}
+		case <-poll.C:
+			n, err := d.RunOnce(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Error("outbox dispatch failed: %v", err)
+			}
+			if n == d.config.BatchSize {
+				poll.Reset(0) // 还有积压，立即继续
+			} else {
+				poll.Reset(d.config.PollInterval)
+			}
+		}
+	}
+}
+
+// RunOnce 认领一批到期事件并投递，返回认领的事件数；认领在短事务中完成，投递时不持有事务与行锁
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	deadline := time.Now().Add(d.config.ClaimTimeout)
+	events, err := d.claim(ctx, deadline)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	batchCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	for i, event := range events {
+		if err := d.deliver(batchCtx, event); err != nil {
+			d.release(events[i:])
+			if ctx.Err() != nil {
+				return len(events), ctx.Err()
+			}
+			if batchCtx.Err() == nil {
+				return len(events), err
+			}
+			logger.Warn("outbox claim expired, %d event(s) released", len(events)-i)
+			break
+		}
+	}
+	return len(events), nil
+}
+
+// claim 认领到期事件：投递次数加一并将 next_attempt_at 推迟到租期结束，其他实例在此之前不会认领
+func (d *Dispatcher) claim(ctx context.Context, until time.Time) ([]*Event, error) {
+	db, err := d.db(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var events []*Event
+	err = db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("status = ? AND next_attempt_at <= ?", StatusPending, time.Now()).
+			Order("id").Limit(d.config.BatchSize)
+		if tx.Dialector.Name() != "sqlite" { // SQLite 不支持行锁，仅用于测试
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&events).Error; err != nil || len(events) == 0 {
+			return err
+		}
+		ids := make([]uint64, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+			event.Attempts++
+		}
+		return tx.Model(&Event{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{"attempts": gorm.Expr("attempts + 1"), "next_attempt_at": until}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// release 释放未投递的事件，撤销本次认领计入的投递次数
+func (d *Dispatcher) release(events []*Event) {
+	for _, event := range events {
+		err := d.updateClaimed(event, map[string]interface{}{"attempts": event.Attempts - 1, "next_attempt_at": time.Now()})
+		if err != nil {
+			logger.Error("outbox release event %d failed: %v", event.ID, err)
+		}
+	}
+}
+
+// updateClaimed 更新仍由本次认领持有的事件，租期结束后被其他实例重新认领时投递次数已改变，更新不再生效；
+// 投递器停止时仍需记录结果，因此不使用调用方的 ctx
+func (d *Dispatcher) updateClaimed(event *Event, updates map[string]interface{}) error {
+	db, err := d.db(context.Background())
+	if err != nil {
+		return err
+	}
+	return db.Model(&Event{}).
+		Where("id = ? AND status = ? AND attempts = ?", event.ID, StatusPending, event.Attempts).
+		Updates(updates).Error
+}
+
+// db 获取当前的连接池，命名连接池重建后使用新连接池
+func (d *Dispatcher) db(ctx context.Context) (*gorm.DB, error) {
+	db, err := d.getDB()
+	if err != nil {
+		return nil, err
+	}
+	return db.WithContext(ctx), nil
+}
+
+// deliver 投递单个已认领的事件并更新状态；投递器停止或认领到期时返回 ctx 的错误，此外仅在更新失败时返回错误
+func (d *Dispatcher) deliver(ctx context.Context, event *Event) error {
+	sendCtx, cancel := context.WithTimeout(ctx, d.config.SendTimeout)
+	err := d.sink.Send(sendCtx, event)
+	cancel()
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err() // 不计入重试次数
+	}
+	now := time.Now()
+	updates := map[string]interface{}{}
+	if err == nil {
+		updates["status"] = StatusDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	} else if d.config.MaxAttempts > 0 && event.Attempts >= d.config.MaxAttempts {
+		updates["status"] = StatusFailed
+		updates["last_error"] = err.Error()
+		logger.Error("outbox event %d (%s) failed after %d attempt(s): %v", event.ID, event.Topic, event.Attempts, err)
+	} else {
+		wait := d.backoff(event.Attempts)
+		updates["next_attempt_at"] = now.Add(wait)
+		updates["last_error"] = err.Error()
+		logger.Warn("outbox event %d (%s) attempt %d failed, retry in %v: %v", event.ID, event.Topic, event.Attempts, wait, err)
+	}
+	return d.updateClaimed(event, updates)
+}
+
+// backoff 第 attempts 次失败后的重试间隔，指数增长并加入抖动
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.config.Backoff << uint(attempts-1)
+	if wait > d.config.MaxBackoff || wait <= 0 {
+		wait = d.config.MaxBackoff
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait)/2+1))
+}
+
+// Cleanup 删除超过保留时间的已投递事件
+func (d *Dispatcher) Cleanup(ctx context.Context) (int64, error) {
+	if d.config.Retention < 0 {
+		return 0, nil
+	}
+	db, err := d.db(ctx)
+	if err != nil {
+		return 0, err
+	}
+	result := db.Where("status = ? AND delivered_at < ?", StatusDelivered, time.Now().Add(-d.config.Retention)).
+		Delete(&Event{})
+	return result.RowsAffected, result.Error
+}
+
+// Retry 将失败的事件重新置为待投递
+func (d *Dispatcher) Retry(ctx context.Context, ids ...uint64) (int64, error) {
+	db, err := d.db(ctx)
+	if err != nil {
+		return 0, err
+	}
+	query := db.Model(&Event{}).Where("status = ?", StatusFailed)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	result := query.Updates(map[string]interface{}{"status": StatusPending, "attempts": 0, "next_attempt_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
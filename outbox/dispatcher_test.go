@@ -0,0 +1,219 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// publish 写入一条事件
+func publish(t *testing.T, db *gorm.DB, topic string) {
+	t.Helper()
+	if err := Publish(db, Message{Topic: topic, Payload: topic}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getEvent 重新读取事件
+func getEvent(t *testing.T, db *gorm.DB, id uint64) *Event {
+	t.Helper()
+	var event Event
+	if err := db.First(&event, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &event
+}
+
+func TestDispatcherDelivers(t *testing.T) {
+	db := newTestDB(t, "default").MySQL("default")
+	publish(t, db, "a")
+	publish(t, db, "b")
+	var topics []string
+	d := NewDispatcher(db, SinkFunc(func(ctx context.Context, event *Event) error {
+		topics = append(topics, event.Topic)
+		return nil
+	}), Config{})
+
+	n, err := d.RunOnce(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("RunOnce = %d, %v, want 2", n, err)
+	}
+	if len(topics) != 2 || topics[0] != "a" || topics[1] != "b" {
+		t.Fatalf("sent %v", topics)
+	}
+	for id := uint64(1); id <= 2; id++ {
+		if event := getEvent(t, db, id); event.Status != StatusDelivered || event.Attempts != 1 || event.DeliveredAt == nil {
+			t.Fatalf("event %d = %+v", id, event)
+		}
+	}
+	if n, err := d.RunOnce(context.Background()); err != nil || n != 0 {
+		t.Fatalf("RunOnce after delivery = %d, %v, want 0", n, err)
+	}
+}
+
+func TestDispatcherRetry(t *testing.T) {
+	db := newTestDB(t, "default").MySQL("default")
+	publish(t, db, "a")
+	fail := true
+	d := NewDispatcher(db, SinkFunc(func(ctx context.Context, event *Event) error {
+		if fail {
+			return errors.New("unavailable")
+		}
+		return nil
+	}), Config{MaxAttempts: 2, Backoff: time.Millisecond * 20, MaxBackoff: time.Millisecond * 20})
+	ctx := context.Background()
+
+	if _, err := d.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	event := getEvent(t, db, 1)
+	if event.Status != StatusPending || event.Attempts != 1 || event.LastError != "unavailable" || !event.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("after first failure = %+v", event)
+	}
+	if n, _ := d.RunOnce(ctx); n != 0 {
+		t.Fatal("event claimed before backoff elapsed")
+	}
+
+	time.Sleep(time.Millisecond * 30)
+	if n, err := d.RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RunOnce after backoff = %d, %v, want 1", n, err)
+	}
+	if event := getEvent(t, db, 1); event.Status != StatusFailed || event.Attempts != 2 {
+		t.Fatalf("after max attempts = %+v", event)
+	}
+
+	if n, err := d.Retry(ctx); err != nil || n != 1 {
+		t.Fatalf("Retry = %d, %v, want 1", n, err)
+	}
+	fail = false
+	if n, err := d.RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RunOnce after Retry = %d, %v, want 1", n, err)
+	}
+	if event := getEvent(t, db, 1); event.Status != StatusDelivered || event.LastError != "" {
+		t.Fatalf("after retry = %+v", event)
+	}
+}
+
+func TestDispatcherClaim(t *testing.T) {
+	db := newTestDB(t, "default").MySQL("default")
+	publish(t, db, "a")
+	sending, unblock := make(chan struct{}), make(chan struct{})
+	slow := NewDispatcher(db, SinkFunc(func(ctx context.Context, event *Event) error {
+		close(sending)
+		<-unblock
+		return nil
+	}), Config{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := slow.RunOnce(context.Background()); err != nil {
+			t.Error(err)
+		}
+	}()
+	<-sending
+
+	// 投递期间不持有事务，其他实例可以写入和认领，但跳过已认领的事件
+	publish(t, db, "b")
+	var topics []string
+	other := NewDispatcher(db, SinkFunc(func(ctx context.Context, event *Event) error {
+		topics = append(topics, event.Topic)
+		return nil
+	}), Config{})
+	if n, err := other.RunOnce(context.Background()); err != nil || n != 1 || topics[0] != "b" {
+		t.Fatalf("other RunOnce = %d, %v, sent %v", n, err, topics)
+	}
+	close(unblock)
+	wg.Wait()
+	if event := getEvent(t, db, 1); event.Status != StatusDelivered || event.Attempts != 1 {
+		t.Fatalf("claimed event = %+v", event)
+	}
+}
+
+func TestDispatcherReleasesOnStop(t *testing.T) {
+	db := newTestDB(t, "default").MySQL("default")
+	publish(t, db, "a")
+	publish(t, db, "b")
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewDispatcher(db, SinkFunc(func(ctx context.Context, event *Event) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}), Config{})
+	if _, err := d.RunOnce(ctx); err != context.Canceled {
+		t.Fatalf("RunOnce = %v, want context.Canceled", err)
+	}
+	// 未完成的投递不计入次数，事件立即可被重新认领
+	for id := uint64(1); id <= 2; id++ {
+		if event := getEvent(t, db, id); event.Status != StatusPending || event.Attempts != 0 || event.NextAttemptAt.After(time.Now()) {
+			t.Fatalf("event %d = %+v", id, event)
+		}
+	}
+}
+
+func TestDispatcherClaimExpires(t *testing.T) {
+	db := newTestDB(t, "default").MySQL("default")
+	publish(t, db, "a")
+	publish(t, db, "b")
+	d := NewDispatcher(db, SinkFunc(func(ctx context.Context, event *Event) error {
+		<-ctx.Done()
+		return errors.New("timeout")
+	}), Config{SendTimeout: time.Millisecond * 100, ClaimTimeout: time.Millisecond * 150})
+	start := time.Now()
+	if n, err := d.RunOnce(context.Background()); err != nil || n != 2 {
+		t.Fatalf("RunOnce = %d, %v, want 2", n, err)
+	}
+	// 整批投递不超过认领租期
+	if elapsed := time.Since(start); elapsed > time.Millisecond*500 {
+		t.Fatalf("batch took %v", elapsed)
+	}
+	if event := getEvent(t, db, 1); event.Attempts != 1 || event.LastError != "timeout" {
+		t.Fatalf("timed out event = %+v", event)
+	}
+	if event := getEvent(t, db, 2); event.Attempts != 0 || event.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("event past claim deadline = %+v", event)
+	}
+}
+
+func TestNamedPoolDispatcherFollowsReplacement(t *testing.T) {
+	old := newTestDB(t, "default").MySQL("default")
+	var sent []string
+	d := NewMySQLDispatcher("default", SinkFunc(func(ctx context.Context, event *Event) error {
+		sent = append(sent, event.Topic)
+		return nil
+	}), Config{})
+
+	// 模拟 ReloadMySQLPool：登记新连接池并关闭旧连接池
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "new.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	publish(t, db, "new")
+	if err := app.SetMySQLPool("default", db); err != nil {
+		t.Fatal(err)
+	}
+	if sqlDB, err := old.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+
+	if n, err := d.RunOnce(context.Background()); err != nil || n != 1 || sent[0] != "new" {
+		t.Fatalf("RunOnce = %d, %v, sent %v", n, err, sent)
+	}
+}
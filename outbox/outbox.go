@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+
+	"gorm.io/gorm"
+)
+
+// 事件状态
+const (
+	StatusPending   = "pending"   // 待投递
+	StatusDelivered = "delivered" // 已投递
+	StatusFailed    = "failed"    // 超过最大重试次数
+)
+
+var ErrNoTransaction = errors.New("outbox: no transaction in context")
+
+// Event 发件箱事件
+type Event struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Topic         string     `gorm:"size:255;not null" json:"topic"`
+	Key           string     `gorm:"size:255" json:"key"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"` // JSON
+	Headers       string     `gorm:"type:text" json:"headers"`          // JSON 对象
+	Status        string     `gorm:"size:16;not null;index:idx_outbox_events_dispatch,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_events_dispatch,priority:2" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `gorm:"index" json:"delivered_at"`
+}
+
+// TableName 发件箱表名
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// HeaderMap 解析事件头
+func (e *Event) HeaderMap() map[string]string {
+	headers := map[string]string{}
+	if e.Headers != "" {
+		_ = json.Unmarshal([]byte(e.Headers), &headers)
+	}
+	return headers
+}
+
+// Message 待发布的消息
+type Message struct {
+	Topic   string
+	Key     string
+	Payload interface{} // 序列化为 JSON，[]byte 与 json.RawMessage 原样写入
+	Headers map[string]string
+}
+
+// AutoMigrate 创建发件箱表
+func AutoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Event{})
+}
+
+// Publish 在调用方的事务中写入事件，事务提交后由 Dispatcher 投递
+func Publish(tx *gorm.DB, messages ...Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	now := time.Now()
+	events := make([]*Event, 0, len(messages))
+	for _, m := range messages {
+		if m.Topic == "" {
+			return errors.New("outbox: message topic is empty")
+		}
+		var payload []byte
+		switch p := m.Payload.(type) {
+		case []byte:
+			payload = p
+		case json.RawMessage:
+			payload = p
+		default:
+			var err error
+			if payload, err = json.Marshal(p); err != nil {
+				return err
+			}
+		}
+		event := &Event{Topic: m.Topic, Key: m.Key, Payload: string(payload), Status: StatusPending, NextAttemptAt: now, CreatedAt: now}
+		if len(m.Headers) > 0 {
+			headers, err := json.Marshal(m.Headers)
+			if err != nil {
+				return err
+			}
+			event.Headers = string(headers)
+		}
+		events = append(events, event)
+	}
+	return tx.Create(events).Error
+}
+
+// PublishContext 在上下文中属于 db 连接池的事务 (见 app.Transaction) 中写入事件
+func PublishContext(ctx context.Context, db *gorm.DB, messages ...Message) error {
+	tx, ok := app.TxFromContext(ctx, db)
+	if !ok {
+		return ErrNoTransaction
+	}
+	return Publish(tx.WithContext(ctx), messages...)
+}
@@ -0,0 +1,81 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/apptest"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB 创建测试环境并建立发件箱表
+func newTestDB(t *testing.T, pools ...string) *apptest.App {
+	t.Helper()
+	a := apptest.New(t, apptest.WithMySQL(pools...))
+	for _, name := range pools {
+		if err := AutoMigrate(a.MySQL(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+func countEvents(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var n int64
+	if err := db.Model(&Event{}).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestPublishContext(t *testing.T) {
+	a := newTestDB(t, "orders", "other")
+	orders, other := a.MySQL("orders"), a.MySQL("other")
+	ctx := context.Background()
+	if err := PublishContext(ctx, orders, Message{Topic: "order.created"}); err != ErrNoTransaction {
+		t.Fatalf("PublishContext without transaction = %v, want ErrNoTransaction", err)
+	}
+
+	err := app.Transaction(ctx, orders, func(ctx context.Context, tx *gorm.DB) error {
+		// 其他连接池没有事务，不能写入 orders 的事务
+		if err := PublishContext(ctx, other, Message{Topic: "order.created"}); err != ErrNoTransaction {
+			t.Errorf("PublishContext on other pool = %v, want ErrNoTransaction", err)
+		}
+		return PublishContext(ctx, orders, Message{
+			Topic:   "order.created",
+			Key:     "1",
+			Payload: map[string]int{"id": 1},
+			Headers: map[string]string{"source": "test"},
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event Event
+	if err := orders.First(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+	if event.Topic != "order.created" || event.Key != "1" || event.Payload != `{"id":1}` || event.Status != StatusPending {
+		t.Fatalf("event = %+v", event)
+	}
+	if h := event.HeaderMap(); h["source"] != "test" {
+		t.Fatalf("headers = %v", h)
+	}
+	if n := countEvents(t, other); n != 0 {
+		t.Fatalf("other pool has %d event(s)", n)
+	}
+
+	// 回滚的事务不留下事件
+	_ = app.Transaction(ctx, orders, func(ctx context.Context, tx *gorm.DB) error {
+		if err := PublishContext(ctx, orders, Message{Topic: "order.canceled"}); err != nil {
+			t.Fatal(err)
+		}
+		return context.Canceled
+	})
+	if n := countEvents(t, orders); n != 1 {
+		t.Fatalf("events after rollback = %d, want 1", n)
+	}
+}
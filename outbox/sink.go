@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MetaverseTopDJ/Scaffold/logger"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// DLTagOutboxEvent LogSink 日志标签
+const DLTagOutboxEvent = "_com_outbox_event"
+
+// Sink 事件投递目标
+type Sink interface {
+	Send(ctx context.Context, event *Event) error
+}
+
+// SinkFunc 函数形式的投递目标
+type SinkFunc func(ctx context.Context, event *Event) error
+
+// Send 实现 Sink
+func (f SinkFunc) Send(ctx context.Context, event *Event) error {
+	return f(ctx, event)
+}
+
+// RedisStreamSink 通过 XADD 投递到 Redis Stream
+type RedisStreamSink struct {
+	Pool   *redis.Pool
+	Stream string // Stream 名称，可包含 %s 替换为事件主题，为空时使用主题
+	MaxLen int    // 近似最大长度，0 表示不限制
+}
+
+// Send 实现 Sink
+func (s *RedisStreamSink) Send(ctx context.Context, event *Event) error {
+	stream := event.Topic
+	if s.Stream != "" {
+		stream = s.Stream
+		if strings.Contains(stream, "%s") {
+			stream = fmt.Sprintf(stream, event.Topic)
+		}
+	}
+	conn, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	args := redis.Args{stream}
+	if s.MaxLen > 0 {
+		args = args.Add("MAXLEN", "~", s.MaxLen)
+	}
+	args = args.Add("*", "id", event.ID, "topic", event.Topic, "key", event.Key, "payload", event.Payload)
+	if event.Headers != "" {
+		args = args.Add("headers", event.Headers)
+	}
+	_, err = redis.DoContext(conn, ctx, "XADD", args...)
+	return err
+}
+
+// WebhookSink 通过 HTTP POST 投递，响应 2xx 视为成功
+type WebhookSink struct {
+	URL     string
+	Client  *http.Client      // 为空时使用 http.DefaultClient
+	Headers map[string]string // 额外请求头
+}
+
+// Send 实现 Sink
+func (s *WebhookSink) Send(ctx context.Context, event *Event) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, strings.NewReader(event.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range event.HeaderMap() {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("X-Outbox-Id", strconv.FormatUint(event.ID, 10))
+	req.Header.Set("X-Outbox-Topic", event.Topic)
+	if event.Key != "" {
+		req.Header.Set("X-Outbox-Key", event.Key)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook %s returned %d: %s", s.URL, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// LogSink 将事件写入日志，用于调试
+type LogSink struct{}
+
+// Send 实现 Sink
+func (LogSink) Send(ctx context.Context, event *Event) error {
+	logger.Log.TagInfo(logger.TraceFromContext(ctx), DLTagOutboxEvent, map[string]interface{}{
+		"id":      event.ID,
+		"topic":   event.Topic,
+		"key":     event.Key,
+		"payload": event.Payload,
+		"headers": event.Headers,
+	})
+	return nil
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// OrderColumn 键集分页排序列
+type OrderColumn struct {
+	Column string
+	Desc   bool
+}
+
+// Cursor 游标内容，Values 为边界记录的排序列值
+type Cursor struct {
+	Values   []json.RawMessage `json:"v"`
+	Backward bool              `json:"b,omitempty"` // 向前翻页
+}
+
+// CursorPage 游标分页结果
+type CursorPage[T any] struct {
+	Items   []T    `json:"items"`
+	Next    string `json:"next,omitempty"` // 下一页游标
+	Prev    string `json:"prev,omitempty"` // 上一页游标
+	HasNext bool   `json:"has_next"`
+	HasPrev bool   `json:"has_prev"`
+}
+
+// Keyset 键集 (游标) 分页，排序列的组合必须唯一，通常以主键结尾；排序列不支持 NULL
+type Keyset struct {
+	secret  []byte
+	columns []OrderColumn
+}
+
+// NewKeyset 创建键集分页，columns 为列名，- 前缀表示倒序，如 "-created_at", "-id"
+func NewKeyset(secret []byte, columns ...string) (*Keyset, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("keyset secret is empty")
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("keyset columns is empty")
+	}
+	k := &Keyset{secret: secret}
+	for _, column := range columns {
+		k.columns = append(k.columns, OrderColumn{Column: strings.TrimLeft(column, "+-"), Desc: strings.HasPrefix(column, "-")})
+	}
+	return k, nil
+}
+
+// Encode 生成签名的游标
+func (k *Keyset) Encode(c Cursor) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(k.sign(payload)), nil
+}
+
+// Decode 校验并解析游标
+func (k *Keyset) Decode(token string) (*Cursor, error) {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return nil, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(token[:i])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(mac, k.sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+	c := &Cursor{}
+	if err := json.Unmarshal(payload, c); err != nil || len(c.Values) != len(k.columns) {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// sign 签名，包含排序列以防游标在不同排序间复用
+func (k *Keyset) sign(payload []byte) []byte {
+	h := hmac.New(sha256.New, k.secret)
+	for _, c := range k.columns {
+		_, _ = fmt.Fprintf(h, "%s:%t;", c.Column, c.Desc)
+	}
+	_, _ = h.Write(payload)
+	return h.Sum(nil)
+}
+
+// Scope 返回 gorm scope，按游标追加条件、排序并查询 size+1 条以判断是否还有数据
+func (k *Keyset) Scope(token string, size int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		c := &Cursor{}
+		if token != "" {
+			var err error
+			if c, err = k.Decode(token); err != nil {
+				_ = db.AddError(err)
+				return db
+			}
+		}
+		if len(c.Values) > 0 {
+			values, err := k.decodeValues(db, c.Values)
+			if err != nil {
+				_ = db.AddError(err)
+				return db
+			}
+			db = db.Where(k.after(values, c.Backward))
+		}
+		for _, column := range k.columns {
+			db = db.Order(clause.OrderByColumn{
+				Column: clause.Column{Table: clause.CurrentTable, Name: column.Column},
+				Desc:   column.Desc != c.Backward,
+			})
+		}
+		return db.Limit(size + 1)
+	}
+}
+
+// after 生成 (a > ?) OR (a = ? AND b > ?) ... 形式的边界条件
+func (k *Keyset) after(values []interface{}, backward bool) clause.Expression {
+	ors := make([]clause.Expression, 0, len(k.columns))
+	for i, column := range k.columns {
+		ands := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: k.columns[j].Column}, Value: values[j]})
+		}
+		col := clause.Column{Table: clause.CurrentTable, Name: column.Column}
+		if column.Desc != backward {
+			ands = append(ands, clause.Lt{Column: col, Value: values[i]})
+		} else {
+			ands = append(ands, clause.Gt{Column: col, Value: values[i]})
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+	if len(ors) == 1 { // 单个表达式的 OrConditions 会以 OR 拼接到前面的条件上
+		return ors[0]
+	}
+	return clause.Or(ors...)
+}
+
+// decodeValues 按模型字段类型还原游标中的值
+func (k *Keyset) decodeValues(db *gorm.DB, raw []json.RawMessage) ([]interface{}, error) {
+	var s *schema.Schema
+	model := db.Statement.Model
+	if model == nil {
+		model = db.Statement.Dest
+	}
+	if model != nil {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err == nil {
+			s = stmt.Schema
+		}
+	}
+	values := make([]interface{}, len(raw))
+	for i, column := range k.columns {
+		if s != nil {
+			if field := s.LookUpField(column.Column); field != nil {
+				v := reflect.New(field.FieldType)
+				if err := json.Unmarshal(raw[i], v.Interface()); err != nil {
+					return nil, ErrInvalidCursor
+				}
+				values[i] = v.Elem().Interface()
+				continue
+			}
+		}
+		d := json.NewDecoder(bytes.NewReader(raw[i]))
+		d.UseNumber()
+		if err := d.Decode(&values[i]); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
+
+// cursorFor 根据记录的排序列值生成游标
+func (k *Keyset) cursorFor(ctx context.Context, s *schema.Schema, item reflect.Value, backward bool) (string, error) {
+	c := Cursor{Values: make([]json.RawMessage, len(k.columns)), Backward: backward}
+	for i, column := range k.columns {
+		field := s.LookUpField(column.Column)
+		if field == nil {
+			return "", fmt.Errorf("keyset column %s not found in %s", column.Column, s.Name)
+		}
+		value, _ := field.ValueOf(ctx, item)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		c.Values[i] = raw
+	}
+	return k.Encode(c)
+}
+
+// Paginate 执行键集分页查询，token 为空时返回第一页
+func Paginate[T any](db *gorm.DB, k *Keyset, token string, size int) (*CursorPage[T], error) {
+	if size <= 0 {
+		size = 15
+	}
+	backward := false
+	if token != "" {
+		c, err := k.Decode(token)
+		if err != nil {
+			return nil, err
+		}
+		backward = c.Backward
+	}
+	page := &CursorPage[T]{Items: []T{}}
+	if err := db.Scopes(k.Scope(token, size)).Find(&page.Items).Error; err != nil {
+		return nil, err
+	}
+	more := len(page.Items) > size
+	if more {
+		page.Items = page.Items[:size]
+	}
+	if backward {
+		for i, j := 0, len(page.Items)-1; i < j; i, j = i+1, j-1 {
+			page.Items[i], page.Items[j] = page.Items[j], page.Items[i]
+		}
+		page.HasPrev, page.HasNext = more, true
+	} else {
+		page.HasPrev, page.HasNext = token != "", more
+	}
+	if len(page.Items) == 0 {
+		return page, nil
+	}
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	var err error
+	if page.HasNext {
+		if page.Next, err = k.cursorFor(db.Statement.Context, stmt.Schema, reflect.ValueOf(&page.Items[len(page.Items)-1]).Elem(), false); err != nil {
+			return nil, err
+		}
+	}
+	if page.HasPrev {
+		if page.Prev, err = k.cursorFor(db.Statement.Context, stmt.Schema, reflect.ValueOf(&page.Items[0]).Elem(), true); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// ListKeyset 按过滤条件进行键集分页查询
+func (r *Repository[T]) ListKeyset(ctx context.Context, k *Keyset, token string, size int, filters map[string]interface{}) (*CursorPage[T], error) {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db, err = r.applyFilters(db.Model(new(T)), filters)
+	if err != nil {
+		return nil, err
+	}
+	return Paginate[T](db, k, token, size)
+}
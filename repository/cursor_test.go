@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+type cursorItem struct {
+	ID     uint `gorm:"primaryKey"`
+	Status string
+}
+
+func TestPaginateSingleColumnWithFilter(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&cursorItem{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		status := "a"
+		if i%2 == 1 {
+			status = "b"
+		}
+		db.Create(&cursorItem{Status: status})
+	}
+	k, err := NewKeyset([]byte("secret"), "-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[uint]bool{}
+	token := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination did not end")
+		}
+		page, err := Paginate[cursorItem](db.Model(&cursorItem{}).Where("status = ?", "a"), k, token, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range page.Items {
+			if item.Status != "a" {
+				t.Fatalf("filter skipped: %+v", item)
+			}
+			if seen[item.ID] {
+				t.Fatalf("item %d returned twice", item.ID)
+			}
+			seen[item.ID] = true
+		}
+		if !page.HasNext {
+			break
+		}
+		token = page.Next
+	}
+	if len(seen) != 5 {
+		t.Fatalf("got %d items, want 5", len(seen))
+	}
+}
@@ -0,0 +1,396 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+	"github.com/MetaverseTopDJ/Scaffold/util"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrNoSoftDelete  = errors.New("model does not support soft delete")
+)
+
+// 过滤操作符
+const (
+	OpEq     = "eq"      // 等于
+	OpNe     = "ne"      // 不等于
+	OpGt     = "gt"      // 大于
+	OpGte    = "gte"     // 大于等于
+	OpLt     = "lt"      // 小于
+	OpLte    = "lte"     // 小于等于
+	OpLike   = "like"    // 包含，值两侧自动加 %
+	OpPrefix = "prefix"  // 前缀匹配
+	OpIn     = "in"      // 在列表中
+	OpIsNull = "is_null" // 值为 true 时 IS NULL，false 时 IS NOT NULL
+)
+
+// Query 列表查询参数
+type Query struct {
+	Filters  map[string]interface{} // 过滤条件，键为 WithFilter 声明的名称
+	Sort     string                 // 排序，如 "-created_at,name"，- 表示倒序
+	Page     uint64                 // 页码，从 1 开始
+	Size     uint64                 // 页面大小，默认 15
+	Unscoped bool                   // 包含软删除的记录
+}
+
+// Page 分页结果
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Total int64  `json:"total"`
+	Page  uint64 `json:"page"`
+	Size  uint64 `json:"size"`
+}
+
+// filter 声明的过滤条件
+type filter struct {
+	column string
+	op     string
+}
+
+// Option 仓储选项
+type Option func(*options)
+
+type options struct {
+	filters     map[string]filter
+	sorts       map[string]string
+	defaultSort string
+}
+
+// WithFilter 声明可过滤字段
+func WithFilter(name, column, op string) Option {
+	return func(o *options) {
+		o.filters[name] = filter{column: column, op: op}
+	}
+}
+
+// WithSort 声明可排序字段
+func WithSort(name, column string) Option {
+	return func(o *options) {
+		o.sorts[name] = column
+	}
+}
+
+// WithDefaultSort 未指定排序时使用的排序，格式同 Query.Sort
+func WithDefaultSort(sort string) Option {
+	return func(o *options) {
+		o.defaultSort = sort
+	}
+}
+
+// Repository 通用仓储，T 为 gorm 模型
+type Repository[T any] struct {
+	getDB func() (*gorm.DB, error)
+	options
+}
+
+// New 创建仓储，getDB 返回使用的连接池
+func New[T any](getDB func() (*gorm.DB, error), opts ...Option) *Repository[T] {
+	r := &Repository[T]{getDB: getDB, options: options{filters: map[string]filter{}, sorts: map[string]string{}}}
+	for _, opt := range opts {
+		opt(&r.options)
+	}
+	return r
+}
+
+// NewPgSQL 创建使用 Postgres 命名连接池的仓储
+func NewPgSQL[T any](pool string, opts ...Option) *Repository[T] {
+	return New[T](func() (*gorm.DB, error) { return app.GetPgSQLPool(pool) }, opts...)
+}
+
+// NewMySQL 创建使用 MySQL 命名连接池的仓储
+func NewMySQL[T any](pool string, opts ...Option) *Repository[T] {
+	return New[T](func() (*gorm.DB, error) { return app.GetMySQLPool(pool) }, opts...)
+}
+
+// DB 获取连接，上下文中已有同一连接池的事务时使用该事务
+func (r *Repository[T]) DB(ctx context.Context) (*gorm.DB, error) {
+	db, err := r.getDB()
+	if err != nil {
+		return nil, err
+	}
+	if tx, ok := app.TxFromContext(ctx, db); ok {
+		return tx.WithContext(ctx), nil
+	}
+	return db.WithContext(ctx), nil
+}
+
+// Get 根据主键获取记录
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pk, err := r.primaryKey(db)
+	if err != nil {
+		return nil, err
+	}
+	var item T
+	if err := db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: pk}, Value: id}).First(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// List 按过滤条件与排序分页查询，同时返回总数
+func (r *Repository[T]) List(ctx context.Context, q Query) (*Page[T], error) {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if q.Unscoped {
+		db = db.Unscoped()
+	}
+	db, err = r.applyFilters(db.Model(new(T)), q.Filters)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page[T]{Page: q.Page, Items: []T{}}
+	if page.Page == 0 {
+		page.Page = 1
+	}
+	size, offset := util.GenPaginationParams(q.Size, page.Page)
+	page.Size = uint64(size)
+	if err := db.Count(&page.Total).Error; err != nil {
+		return nil, err
+	}
+	if page.Total == 0 || int64(offset) >= page.Total {
+		return page, nil
+	}
+	db, err = r.applySort(db, q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Limit(size).Offset(offset).Find(&page.Items).Error; err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Create 创建记录
+func (r *Repository[T]) Create(ctx context.Context, item *T) error {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Create(item).Error
+}
+
+// Update 保存记录的所有字段
+func (r *Repository[T]) Update(ctx context.Context, item *T) error {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Save(item).Error
+}
+
+// Updates 根据主键更新指定字段
+func (r *Repository[T]) Updates(ctx context.Context, id interface{}, values map[string]interface{}) error {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return err
+	}
+	pk, err := r.primaryKey(db)
+	if err != nil {
+		return err
+	}
+	return db.Model(new(T)).Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: pk}, Value: id}).Updates(values).Error
+}
+
+// Delete 根据主键删除记录，模型包含 gorm.DeletedAt 时为软删除
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return err
+	}
+	return r.delete(db, id)
+}
+
+// ForceDelete 根据主键永久删除记录
+func (r *Repository[T]) ForceDelete(ctx context.Context, id interface{}) error {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return err
+	}
+	return r.delete(db.Unscoped(), id)
+}
+
+// Restore 恢复软删除的记录
+func (r *Repository[T]) Restore(ctx context.Context, id interface{}) error {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := r.schema(db)
+	if err != nil {
+		return err
+	}
+	field := softDeleteField(s)
+	if field == nil {
+		return ErrNoSoftDelete
+	}
+	pk, err := r.primaryKey(db)
+	if err != nil {
+		return err
+	}
+	return db.Unscoped().Model(new(T)).Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: pk}, Value: id}).Update(field.DBName, nil).Error
+}
+
+// SoftDelete 模型是否支持软删除
+func (r *Repository[T]) SoftDelete(ctx context.Context) (bool, error) {
+	db, err := r.DB(ctx)
+	if err != nil {
+		return false, err
+	}
+	s, err := r.schema(db)
+	if err != nil {
+		return false, err
+	}
+	return softDeleteField(s) != nil, nil
+}
+
+func (r *Repository[T]) delete(db *gorm.DB, id interface{}) error {
+	pk, err := r.primaryKey(db)
+	if err != nil {
+		return err
+	}
+	result := db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: pk}, Value: id}).Delete(new(T))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// schema 解析模型结构，gorm 会缓存解析结果
+func (r *Repository[T]) schema(db *gorm.DB) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
+// primaryKey 主键列名
+func (r *Repository[T]) primaryKey(db *gorm.DB) (string, error) {
+	s, err := r.schema(db)
+	if err != nil {
+		return "", err
+	}
+	if s.PrioritizedPrimaryField == nil {
+		return "", fmt.Errorf("model %s has no primary key", s.Name)
+	}
+	return s.PrioritizedPrimaryField.DBName, nil
+}
+
+// softDeleteField 软删除字段
+func softDeleteField(s *schema.Schema) *schema.Field {
+	deletedAt := reflect.TypeOf(gorm.DeletedAt{})
+	for _, field := range s.Fields {
+		if field.FieldType == deletedAt {
+			return field
+		}
+	}
+	return nil
+}
+
+// applyFilters 应用声明的过滤条件，未声明的名称返回 ErrInvalidFilter
+func (r *Repository[T]) applyFilters(db *gorm.DB, filters map[string]interface{}) (*gorm.DB, error) {
+	for name, value := range filters {
+		f, ok := r.filters[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFilter, name)
+		}
+		column := clause.Column{Table: clause.CurrentTable, Name: f.column}
+		var expr clause.Expression
+		switch f.op {
+		case OpEq, "":
+			expr = clause.Eq{Column: column, Value: value}
+		case OpNe:
+			expr = clause.Neq{Column: column, Value: value}
+		case OpGt:
+			expr = clause.Gt{Column: column, Value: value}
+		case OpGte:
+			expr = clause.Gte{Column: column, Value: value}
+		case OpLt:
+			expr = clause.Lt{Column: column, Value: value}
+		case OpLte:
+			expr = clause.Lte{Column: column, Value: value}
+		case OpLike:
+			expr = clause.Like{Column: column, Value: "%" + escapeLike(fmt.Sprint(value)) + "%"}
+		case OpPrefix:
+			expr = clause.Like{Column: column, Value: escapeLike(fmt.Sprint(value)) + "%"}
+		case OpIn:
+			values, err := toSlice(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s %v", ErrInvalidFilter, name, err)
+			}
+			expr = clause.IN{Column: column, Values: values}
+		case OpIsNull:
+			isNull, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s requires bool", ErrInvalidFilter, name)
+			}
+			expr = clause.Eq{Column: column, Value: nil}
+			if !isNull {
+				expr = clause.Neq{Column: column, Value: nil}
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s has unknown operator %q", ErrInvalidFilter, name, f.op)
+		}
+		db = db.Where(expr)
+	}
+	return db, nil
+}
+
+// applySort 应用排序，未声明的名称返回 ErrInvalidSort
+func (r *Repository[T]) applySort(db *gorm.DB, sort string) (*gorm.DB, error) {
+	if sort == "" {
+		sort = r.defaultSort
+	}
+	for _, item := range strings.Split(sort, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		desc := strings.HasPrefix(item, "-")
+		name := strings.TrimLeft(item, "+-")
+		column, ok := r.sorts[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSort, name)
+		}
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Desc: desc})
+	}
+	return db, nil
+}
+
+// escapeLike 转义 LIKE 通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// toSlice 将切片类型的值转为 []interface{}
+func toSlice(value interface{}) ([]interface{}, error) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("requires slice, got %T", value)
+	}
+	values := make([]interface{}, v.Len())
+	for i := range values {
+		values[i] = v.Index(i).Interface()
+	}
+	return values, nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type repoItem struct {
+	ID   uint `gorm:"primaryKey"`
+	Name string
+}
+
+func openRepoTestDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if err := db.AutoMigrate(&repoItem{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRepositoryUsesTransactionOfItsPool(t *testing.T) {
+	a, b := openRepoTestDB(t, "a.db"), openRepoTestDB(t, "b.db")
+	repoA := New[repoItem](func() (*gorm.DB, error) { return a, nil })
+	repoB := New[repoItem](func() (*gorm.DB, error) { return b, nil })
+	rollback := errors.New("rollback")
+
+	err := app.Transaction(context.Background(), a, func(ctx context.Context, tx *gorm.DB) error {
+		if err := repoA.Create(ctx, &repoItem{Name: "in tx"}); err != nil {
+			return err
+		}
+		// b 不属于 a 的事务，直接写入 b
+		if err := repoB.Create(ctx, &repoItem{Name: "outside tx"}); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if err != rollback {
+		t.Fatalf("Transaction = %v", err)
+	}
+	var n int64
+	if err := a.Model(&repoItem{}).Count(&n).Error; err != nil || n != 0 {
+		t.Fatalf("pool a items = %d, %v, want rolled back", n, err)
+	}
+	if err := b.Model(&repoItem{}).Count(&n).Error; err != nil || n != 1 {
+		t.Fatalf("pool b items = %d, %v, want 1", n, err)
+	}
+}
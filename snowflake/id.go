@@ -0,0 +1,57 @@
+package snowflake
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+
+	"github.com/MetaverseTopDJ/Scaffold/util"
+)
+
+// ID 雪花 ID，JSON 序列化为字符串，避免 JavaScript 丢失精度
+type ID int64
+
+// Generate 使用进程级生成器 (见 base 配置 snowflake) 生成 ID
+func Generate() (ID, error) {
+	id, err := util.GenSnowFlakeID()
+	return ID(id), err
+}
+
+// ParseID 解析十进制字符串
+func ParseID(s string) (ID, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	return ID(id), err
+}
+
+// Int64 整数值
+func (id ID) Int64() int64 {
+	return int64(id)
+}
+
+// String 十进制字符串
+func (id ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// MarshalJSON 序列化为字符串
+func (id ID) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + id.String() + `"`), nil
+}
+
+// UnmarshalJSON 兼容字符串与数字
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	s := string(bytes.Trim(data, `"`))
+	if s == "" {
+		*id = 0
+		return nil
+	}
+	v, err := ParseID(s)
+	if err != nil {
+		return errors.New("snowflake: invalid id " + string(data))
+	}
+	*id = v
+	return nil
+}
@@ -0,0 +1,101 @@
+package snowflake
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/MetaverseTopDJ/Scaffold/app"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// TagSnowflake 需要填充雪花 ID 的字段标签，如 `snowflake:""`；类型为 ID 的主键无需标签
+const TagSnowflake = "snowflake"
+
+var idType = reflect.TypeOf(ID(0))
+
+// Plugin 创建记录时为零值的雪花 ID 字段生成 ID，主键需标记 autoIncrement:false 以免建表时生成自增列
+type Plugin struct{}
+
+// Register 为所有 MySQL / Postgres 命名连接池注册雪花 ID 插件
+func Register() error {
+	return app.RegisterSQLPlugin(func(driver, name string) gorm.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Name 插件名称
+func (p *Plugin) Name() string {
+	return "scaffold:snowflake"
+}
+
+// Initialize 注册回调
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("scaffold:snowflake", fill)
+}
+
+// fields 模型中需要填充的字段
+func fields(s *schema.Schema) []*schema.Field {
+	var list []*schema.Field
+	for _, field := range s.Fields {
+		if _, ok := field.Tag.Lookup(TagSnowflake); ok || (field.PrimaryKey && field.FieldType == idType) {
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
+func fill(db *gorm.DB) {
+	if db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+	list := fields(db.Statement.Schema)
+	if len(list) == 0 {
+		return
+	}
+	value := db.Statement.ReflectValue
+	if value.Kind() == reflect.Struct && !value.CanAddr() { // Create(struct) 传入的值无法修改，改为创建其副本
+		copied := reflect.New(value.Type())
+		copied.Elem().Set(value)
+		db.Statement.Dest, db.Statement.ReflectValue = copied.Interface(), copied.Elem()
+		value = copied.Elem()
+	}
+	fillValue(db, value, list)
+}
+
+// fillValue 为结构体或切片中的每条记录填充 ID
+func fillValue(db *gorm.DB, value reflect.Value, list []*schema.Field) {
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			fillValue(db, value.Index(i), list)
+		}
+	case reflect.Struct:
+		if !value.CanAddr() { // 按值传入的数组元素无法修改，跳过
+			return
+		}
+		ctx := db.Statement.Context
+		for _, field := range list {
+			if _, zero := field.ValueOf(ctx, value); !zero {
+				continue
+			}
+			id, err := Generate()
+			if err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			var v interface{} = int64(id)
+			if field.FieldType.Kind() == reflect.String {
+				v = strconv.FormatInt(int64(id), 10)
+			}
+			if err := field.Set(ctx, value, v); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package snowflake
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type row struct {
+	ID   ID     `gorm:"primaryKey;autoIncrement:false"`
+	Ref  string `snowflake:""`
+	Name string
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "snowflake.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	if err := db.Use(&Plugin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&row{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPluginFillsIDs(t *testing.T) {
+	db := openTestDB(t)
+	r := row{Name: "a", Ref: "kept"}
+	if err := db.Create(&r).Error; err != nil {
+		t.Fatal(err)
+	}
+	if r.ID == 0 || r.Ref != "kept" {
+		t.Fatalf("Create(&row) = %+v", r)
+	}
+	rows := []row{{Name: "b"}, {Name: "c"}}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if rows[0].ID == 0 || rows[1].ID == 0 || rows[0].ID == rows[1].ID || rows[0].Ref == "" {
+		t.Fatalf("Create(&[]row) = %+v", rows)
+	}
+}
+
+func TestPluginUnaddressableValue(t *testing.T) {
+	db := openTestDB(t)
+	// 按值传入时为副本填充 ID，不能 panic
+	if err := db.Create(row{Name: "a"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var got row
+	if err := db.Where("name = ?", "a").First(&got).Error; err != nil {
+		t.Fatal(err)
+	}
+	if got.ID == 0 || got.Ref == "" {
+		t.Fatalf("stored row = %+v", got)
+	}
+}
@@ -25,9 +25,49 @@ type SnowFlake struct {
 	lastTimestamp uint64
 	sequence      uint32
 	workerID      uint32
+	epoch         int64      // 起始时间 (毫秒)
 	lock          sync.Mutex // 互斥锁
 }
 
+var snowFlakeLock sync.Mutex
+
+// InitSnowFlake 设置进程级 ID 生成器
+func InitSnowFlake(workerID uint32, start time.Time) error {
+	sf, err := NewSnowFlakeWithEpoch(workerID, start)
+	if err != nil {
+		return err
+	}
+	snowFlakeLock.Lock()
+	snowFlake = sf
+	snowFlakeLock.Unlock()
+	return nil
+}
+
+// GetSnowFlake 获取进程级 ID 生成器，未初始化时返回 nil
+func GetSnowFlake() *SnowFlake {
+	snowFlakeLock.Lock()
+	defer snowFlakeLock.Unlock()
+	return snowFlake
+}
+
+// SetSnowFlake 替换进程级 ID 生成器，nil 表示恢复为未初始化，用于测试
+func SetSnowFlake(sf *SnowFlake) {
+	snowFlakeLock.Lock()
+	snowFlake = sf
+	snowFlakeLock.Unlock()
+}
+
+// GenSnowFlakeID 使用进程级生成器生成 ID，未初始化时使用 worker 1
+func GenSnowFlakeID() (uint64, error) {
+	snowFlakeLock.Lock()
+	if snowFlake == nil {
+		snowFlake = &SnowFlake{workerID: 1, epoch: epoch}
+	}
+	sf := snowFlake
+	snowFlakeLock.Unlock()
+	return sf.Generate()
+}
+
 func (sf *SnowFlake) pack() uint64 {
 	uuid := (sf.lastTimestamp << (numWorkerBits + numSequenceBits)) | (uint64(sf.workerID) << numSequenceBits) | (uint64(sf.sequence))
 	return uuid
@@ -38,7 +78,22 @@ func NewSnowFlake(workerID uint32) (*SnowFlake, error) {
 	if workerID <= 0 || workerID > MaxWorkID {
 		return nil, errors.New("InvalidWorkerId") // 无效的进程ID
 	}
-	return &SnowFlake{workerID: workerID}, nil
+	return &SnowFlake{workerID: workerID, epoch: epoch}, nil
+}
+
+// NewSnowFlakeWithEpoch 使用自定义起始时间创建生成器，起始时间为零值时使用默认值
+func NewSnowFlakeWithEpoch(workerID uint32, start time.Time) (*SnowFlake, error) {
+	sf, err := NewSnowFlake(workerID)
+	if err != nil {
+		return nil, err
+	}
+	if !start.IsZero() {
+		if start.After(time.Now()) {
+			return nil, errors.New("InvalidEpoch") // 起始时间晚于当前时间
+		}
+		sf.epoch = start.UnixNano() / int64(time.Millisecond)
+	}
+	return sf, nil
 }
 
 // Generate Next creates and returns a unique snowflake ID
@@ -47,7 +102,7 @@ func (sf *SnowFlake) Generate() (uint64, error) {
 	sf.lock.Lock()
 	defer sf.lock.Unlock()
 
-	ts := timestamp()
+	ts := sf.timestamp()
 	if ts == sf.lastTimestamp {
 		sf.sequence = (sf.sequence + 1) & MaxSequence
 		if sf.sequence == 0 {
@@ -70,12 +125,12 @@ func (sf *SnowFlake) Generate() (uint64, error) {
 func (sf *SnowFlake) waitNextMilli(ts uint64) uint64 {
 	for ts == sf.lastTimestamp {
 		time.Sleep(100 * time.Microsecond)
-		ts = timestamp()
+		ts = sf.timestamp()
 	}
 	return ts
 }
 
 // timestamp
-func timestamp() uint64 {
-	return uint64(time.Now().UnixNano()/int64(1000000) - epoch)
+func (sf *SnowFlake) timestamp() uint64 {
+	return uint64(time.Now().UnixNano()/int64(1000000) - sf.epoch)
 }
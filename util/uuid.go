@@ -6,16 +6,12 @@ import (
 	"strconv"
 )
 
-var snowFlake *SnowFlake
+var snowFlake *SnowFlake // 进程级 ID 生成器，见 InitSnowFlake
 
 // GenUUID 生成唯一ID
 func GenUUID() (uuid string, err error) {
 	var i uint64
-	snowFlake, err = NewSnowFlake(1)
-	if err != nil {
-		return "", err
-	}
-	if i, err = snowFlake.Generate(); err != nil {
+	if i, err = GenSnowFlakeID(); err != nil {
 		return "", err
 	}
 	m := md5.New()